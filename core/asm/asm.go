@@ -15,7 +15,11 @@ type instructionIterator struct {
 	started bool
 }
 
+// NewInstructionIterator returns an iterator over the instructions of code.
+// A solc metadata trailer is data, not code, and is not iterated over, see
+// SplitMetadata.
 func NewInstructionIterator(code []byte) *instructionIterator {
+	code, _ = SplitMetadata(code)
 	return &instructionIterator{code: code}
 }
 
@@ -75,6 +79,11 @@ func (it *instructionIterator) Op() vm.OpCode {
 	return it.op
 }
 
+// Code returns the code iterated over, without its metadata trailer.
+func (it *instructionIterator) Code() []byte {
+	return it.code
+}
+
 // Arg returns the argument of the current instruction.
 func (it *instructionIterator) Arg() []byte {
 	return it.arg
@@ -0,0 +1,221 @@
+package asm
+
+import (
+	"fadingrose/rosy-nigh/core/vm"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+var (
+	identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// pcPrefix is the pc column printed by Disassemble
+	pcPrefix = regexp.MustCompile(`^[0-9a-fA-F]{5,}:$`)
+)
+
+// asmItem is a label definition or an instruction of the source
+type asmItem struct {
+	line    int
+	label   string // label defined at this position, no instruction
+	op      vm.OpCode
+	auto    bool   // bare PUSH, sized during layout
+	size    int    // bytes of the push argument
+	operand string // push operand as written
+	ref     string // label the push refers to
+	value   *big.Int
+}
+
+// Assemble turns assembly into bytecode. The syntax is the one printed by
+// Disassemble, one instruction per line with an optional pc prefix, plus:
+//
+//	; comments, also starting with //
+//	#define SLOT 0x01   constants usable as push operands
+//	loop:               label, marks the next instruction (JUMPDEST is not implied)
+//	PUSH2 @loop         push of a label pc
+//	PUSH @loop          push sized to fit its operand, labels included
+//
+// Push operands are decimal or 0x prefixed hex numbers, constants or label
+// references.
+func Assemble(src string) ([]byte, error) {
+	var (
+		items     []*asmItem
+		constants = make(map[string]*big.Int)
+		labels    = make(map[string]uint64)
+	)
+	for i, line := range strings.Split(src, "\n") {
+		lineno := i + 1
+		if idx := strings.Index(line, ";"); idx >= 0 {
+			line = line[:idx]
+		}
+		if idx := strings.Index(line, "//"); idx >= 0 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if fields[0] == "#define" {
+			if len(fields) != 3 || !identifier.MatchString(fields[1]) {
+				return nil, fmt.Errorf("line %d: expected #define NAME VALUE", lineno)
+			}
+			value, ok := new(big.Int).SetString(fields[2], 0)
+			if !ok || value.Sign() < 0 {
+				return nil, fmt.Errorf("line %d: invalid value %q", lineno, fields[2])
+			}
+			if _, ok := constants[fields[1]]; ok {
+				return nil, fmt.Errorf("line %d: %s redefined", lineno, fields[1])
+			}
+			constants[fields[1]] = value
+			continue
+		}
+		if len(fields) > 1 && pcPrefix.MatchString(fields[0]) {
+			fields = fields[1:]
+		}
+		if name, ok := strings.CutSuffix(fields[0], ":"); ok {
+			if !identifier.MatchString(name) {
+				return nil, fmt.Errorf("line %d: invalid label %q", lineno, name)
+			}
+			if _, ok := labels[name]; ok {
+				return nil, fmt.Errorf("line %d: label %s redefined", lineno, name)
+			}
+			labels[name] = 0
+			items = append(items, &asmItem{line: lineno, label: name})
+			if fields = fields[1:]; len(fields) == 0 {
+				continue
+			}
+		}
+
+		item, err := parseInstruction(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineno, err)
+		}
+		item.line = lineno
+		items = append(items, item)
+	}
+
+	// resolve operands now that every constant and label is known
+	for _, item := range items {
+		if item.operand == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(item.operand, "@"):
+			item.ref = item.operand[1:]
+			if _, ok := labels[item.ref]; !ok {
+				return nil, fmt.Errorf("line %d: undefined label %s", item.line, item.ref)
+			}
+			if item.auto {
+				// like PUSH 0, a label at pc 0 is no PUSH0
+				item.size = 1
+			}
+		case identifier.MatchString(item.operand):
+			value, ok := constants[item.operand]
+			if !ok {
+				return nil, fmt.Errorf("line %d: undefined constant %s", item.line, item.operand)
+			}
+			item.value = value
+		default:
+			value, ok := new(big.Int).SetString(item.operand, 0)
+			if !ok || value.Sign() < 0 {
+				return nil, fmt.Errorf("line %d: invalid operand %q", item.line, item.operand)
+			}
+			item.value = value
+		}
+		if item.value != nil {
+			if item.auto {
+				item.size = max(1, byteLen(item.value))
+			} else if byteLen(item.value) > item.size {
+				return nil, fmt.Errorf("line %d: %s does not fit %v", item.line, item.operand, item.op)
+			}
+		}
+		if item.size > 32 {
+			return nil, fmt.Errorf("line %d: %s does not fit a push", item.line, item.operand)
+		}
+	}
+
+	// label pcs depend on the size of pushes referring to labels, grow them
+	// until every label fits
+	for {
+		var pc uint64
+		for _, item := range items {
+			if item.label != "" {
+				labels[item.label] = pc
+				continue
+			}
+			pc += 1 + uint64(item.size)
+		}
+		grown := false
+		for _, item := range items {
+			if item.ref == "" {
+				continue
+			}
+			need := byteLen(new(big.Int).SetUint64(labels[item.ref]))
+			if need <= item.size {
+				continue
+			}
+			if !item.auto {
+				return nil, fmt.Errorf("line %d: @%s does not fit %v", item.line, item.ref, item.op)
+			}
+			item.size, grown = need, true
+		}
+		if !grown {
+			break
+		}
+	}
+
+	var code []byte
+	for _, item := range items {
+		if item.label != "" {
+			continue
+		}
+		if item.auto {
+			item.op = vm.PUSH0 + vm.OpCode(item.size)
+		}
+		code = append(code, byte(item.op))
+		if item.size == 0 {
+			continue
+		}
+		value := item.value
+		if item.ref != "" {
+			value = new(big.Int).SetUint64(labels[item.ref])
+		}
+		code = append(code, value.FillBytes(make([]byte, item.size))...)
+	}
+	return code, nil
+}
+
+// parseInstruction parses a mnemonic and its operand.
+func parseInstruction(fields []string) (*asmItem, error) {
+	mnemonic := strings.ToUpper(fields[0])
+	item := &asmItem{}
+	switch {
+	case mnemonic == "PUSH":
+		item.auto = true
+	case vm.IsValidString(mnemonic):
+		item.op = vm.StringToOp(mnemonic)
+		if item.op.IsPush() {
+			item.size = int(item.op - vm.PUSH0)
+		}
+	default:
+		return nil, fmt.Errorf("unknown instruction %s", fields[0])
+	}
+
+	takesOperand := item.auto || item.size > 0
+	switch {
+	case takesOperand && len(fields) != 2:
+		return nil, fmt.Errorf("%s expects one operand", mnemonic)
+	case !takesOperand && len(fields) != 1:
+		return nil, fmt.Errorf("%s takes no operand", mnemonic)
+	}
+	if takesOperand {
+		item.operand = fields[1]
+	}
+	return item, nil
+}
+
+// byteLen returns the number of bytes needed to represent v.
+func byteLen(v *big.Int) int {
+	return (v.BitLen() + 7) / 8
+}
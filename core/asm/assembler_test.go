@@ -0,0 +1,83 @@
+package asm
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestAssemble(t *testing.T) {
+	for i, tc := range []struct {
+		src     string
+		want    string
+		wantErr string
+	}{
+		{src: "PUSH1 0x04\nJUMP\nINVALID\nJUMPDEST\nSTOP", want: "600456fe5b00"},
+		{src: "push2 258 ; decimal operand\nstop // trailing comment", want: "61010200"},
+		{src: "PUSH0\nPUSH 0\nPUSH 0x1234\nPUSH32 1", want: "5f60006112347f" + strings.Repeat("00", 31) + "01"},
+		{
+			src: `
+#define SLOT 0x01
+CALLDATASIZE
+PUSH @end
+JUMPI
+PUSH SLOT
+SLOAD
+end:
+JUMPDEST
+STOP`,
+			want: "366007576001545b00",
+		},
+		{
+			// labels referenced before definition, auto-sized past one byte
+			src:  "PUSH @far\nJUMP\n" + strings.Repeat("JUMPDEST\n", 256) + "far: JUMPDEST",
+			want: "61010456" + strings.Repeat("5b", 257),
+		},
+		{src: "start: JUMPDEST\nPUSH @start\nJUMP", want: "5b600056"},
+		{src: "00000: PUSH1 0x04\n00002: JUMP", want: "600456"},
+		{src: "FOO", wantErr: "line 1: unknown instruction FOO"},
+		{src: "ADD 1", wantErr: "line 1: ADD takes no operand"},
+		{src: "PUSH1", wantErr: "line 1: PUSH1 expects one operand"},
+		{src: "PUSH1 0x100", wantErr: "line 1: 0x100 does not fit PUSH1"},
+		{src: "PUSH @nowhere", wantErr: "line 1: undefined label nowhere"},
+		{src: "\nPUSH NOPE", wantErr: "line 2: undefined constant NOPE"},
+		{src: "a:\na:", wantErr: "line 2: label a redefined"},
+		{src: "PUSH1 @far\n" + strings.Repeat("STOP\n", 256) + "far:", wantErr: "line 1: @far does not fit PUSH1"},
+		{src: "#define X", wantErr: "line 1: expected #define NAME VALUE"},
+	} {
+		have, err := Assemble(tc.src)
+		haveErr := ""
+		if err != nil {
+			haveErr = err.Error()
+		}
+		if haveErr != tc.wantErr {
+			t.Errorf("test %d: encountered error: %q want %q", i, haveErr, tc.wantErr)
+			continue
+		}
+		if hex.EncodeToString(have) != tc.want {
+			t.Errorf("test %d: wrong code, have %x want %s", i, have, tc.want)
+		}
+	}
+}
+
+// Tests that disassembled code assembles back to itself
+func TestAssembleDisassembled(t *testing.T) {
+	for _, src := range []string{
+		"6080604052348015600f57600080fd5b50603f80601d6000396000f3fe",
+		"3660095760006000fd5b00",
+		"5f5f5f7f00000000000000000000000000000000000000000000000000000000000000ff00",
+	} {
+		code, _ := hex.DecodeString(src)
+		listing, err := Disassemble(code)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		have, err := Assemble(strings.Join(listing, ""))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if hex.EncodeToString(have) != src {
+			t.Errorf("round trip failed, have %x want %s", have, src)
+		}
+	}
+}
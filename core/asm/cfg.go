@@ -0,0 +1,161 @@
+package asm
+
+import (
+	"fadingrose/rosy-nigh/core/vm"
+	"math/big"
+	"sort"
+)
+
+// Instruction is a single decoded instruction.
+type Instruction struct {
+	PC  uint64
+	Op  vm.OpCode
+	Arg []byte
+}
+
+// BasicBlock is a maximal straight-line run of instructions, it is only
+// entered at its first instruction and only left after its last one.
+type BasicBlock struct {
+	// Start is the pc of the first instruction, End the pc right after the
+	// last instruction and its argument.
+	Start, End   uint64
+	Instructions []Instruction
+
+	succs []*BasicBlock
+	preds []*BasicBlock
+}
+
+// Successors returns the blocks control may flow to after b.
+func (b *BasicBlock) Successors() []*BasicBlock {
+	return b.succs
+}
+
+// Predecessors returns the blocks control may flow from into b.
+func (b *BasicBlock) Predecessors() []*BasicBlock {
+	return b.preds
+}
+
+// Last returns the last instruction of b.
+func (b *BasicBlock) Last() Instruction {
+	return b.Instructions[len(b.Instructions)-1]
+}
+
+// CFG is the control flow graph of a piece of bytecode. Jumps are resolved
+// when their target is pushed right before them, other jumps are left
+// without an edge and listed as unresolved.
+type CFG struct {
+	// Blocks are ordered by pc, the first one is the entry
+	Blocks []*BasicBlock
+	// Unresolved are the blocks ending with a jump of unknown target
+	Unresolved []*BasicBlock
+
+	byStart map[uint64]*BasicBlock
+}
+
+// NewCFG splits code into basic blocks and links them. A truncated PUSH at
+// the end of code is kept, its missing bytes read as zero like in the EVM.
+// A metadata trailer is not code, see SplitMetadata.
+func NewCFG(code []byte) *CFG {
+	cfg := &CFG{byStart: make(map[uint64]*BasicBlock)}
+
+	var current *BasicBlock
+	emit := func(ins Instruction, next uint64) {
+		if current == nil || ins.Op == vm.JUMPDEST {
+			current = &BasicBlock{Start: ins.PC}
+			cfg.Blocks = append(cfg.Blocks, current)
+			cfg.byStart[ins.PC] = current
+		}
+		current.Instructions = append(current.Instructions, ins)
+		current.End = next
+		if endsBlock(ins.Op) {
+			current = nil
+		}
+	}
+
+	it := NewInstructionIterator(code)
+	for it.Next() {
+		emit(Instruction{PC: it.PC(), Op: it.Op(), Arg: it.Arg()}, it.PC()+1+uint64(len(it.Arg())))
+	}
+	if it.Error() != nil {
+		runtime := it.Code()
+		arg := make([]byte, int(it.Op()-vm.PUSH0))
+		copy(arg, runtime[it.PC()+1:])
+		emit(Instruction{PC: it.PC(), Op: it.Op(), Arg: arg}, uint64(len(runtime)))
+	}
+
+	for i, b := range cfg.Blocks {
+		last := b.Last()
+		if last.Op == vm.JUMP || last.Op == vm.JUMPI {
+			if target, ok := b.jumpTarget(); !ok {
+				cfg.Unresolved = append(cfg.Unresolved, b)
+			} else if dest := cfg.byStart[target]; dest != nil && dest.Instructions[0].Op == vm.JUMPDEST {
+				// a static jump to anything but a JUMPDEST fails, no edge
+				link(b, dest)
+			}
+		}
+		if !haltsFlow(last.Op) && i+1 < len(cfg.Blocks) {
+			link(b, cfg.Blocks[i+1])
+		}
+	}
+	return cfg
+}
+
+// Entry returns the block execution starts at, nil for empty code.
+func (c *CFG) Entry() *BasicBlock {
+	if len(c.Blocks) == 0 {
+		return nil
+	}
+	return c.Blocks[0]
+}
+
+// Block returns the block containing the instruction at pc, or nil.
+func (c *CFG) Block(pc uint64) *BasicBlock {
+	i := sort.Search(len(c.Blocks), func(i int) bool { return c.Blocks[i].End > pc })
+	if i == len(c.Blocks) || c.Blocks[i].Start > pc {
+		return nil
+	}
+	return c.Blocks[i]
+}
+
+// jumpTarget returns the statically known target of the jump ending b.
+func (b *BasicBlock) jumpTarget() (uint64, bool) {
+	if len(b.Instructions) < 2 {
+		return 0, false
+	}
+	push := b.Instructions[len(b.Instructions)-2]
+	if !push.Op.IsPush() {
+		return 0, false
+	}
+	target := new(big.Int).SetBytes(push.Arg)
+	if !target.IsUint64() {
+		// out of any code, the jump fails
+		return ^uint64(0), true
+	}
+	return target.Uint64(), true
+}
+
+func link(from, to *BasicBlock) {
+	for _, succ := range from.succs {
+		if succ == to {
+			return
+		}
+	}
+	from.succs = append(from.succs, to)
+	to.preds = append(to.preds, from)
+}
+
+// endsBlock reports whether op is the last instruction of its block.
+func endsBlock(op vm.OpCode) bool {
+	return op == vm.JUMPI || haltsFlow(op)
+}
+
+// haltsFlow reports whether control never falls through op to the next
+// instruction.
+func haltsFlow(op vm.OpCode) bool {
+	switch op {
+	case vm.STOP, vm.JUMP, vm.RETURN, vm.REVERT, vm.INVALID, vm.SELFDESTRUCT:
+		return true
+	}
+	// undefined opcodes abort execution
+	return !vm.IsValidString(op.String())
+}
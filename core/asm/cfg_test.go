@@ -0,0 +1,157 @@
+package asm
+
+import (
+	"encoding/hex"
+	"fadingrose/rosy-nigh/core/vm"
+	"strings"
+	"testing"
+)
+
+// starts returns the start pcs of blocks
+func starts(blocks []*BasicBlock) []uint64 {
+	ret := make([]uint64, 0, len(blocks))
+	for _, b := range blocks {
+		ret = append(ret, b.Start)
+	}
+	return ret
+}
+
+func equal(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCFG(t *testing.T) {
+	for i, tc := range []struct {
+		code       string
+		blocks     []uint64
+		succs      map[uint64][]uint64
+		preds      map[uint64][]uint64
+		unresolved []uint64
+	}{
+		{
+			// PUSH1 4 JUMP INVALID JUMPDEST STOP
+			code:   "600456fe5b00",
+			blocks: []uint64{0, 3, 4},
+			succs:  map[uint64][]uint64{0: {4}, 3: {}, 4: {}},
+			preds:  map[uint64][]uint64{0: {}, 3: {}, 4: {0}},
+		},
+		{
+			// CALLDATASIZE PUSH1 9 JUMPI PUSH1 0 PUSH1 0 REVERT JUMPDEST STOP
+			code:   "3660095760006000fd5b00",
+			blocks: []uint64{0, 4, 9},
+			succs:  map[uint64][]uint64{0: {9, 4}, 4: {}, 9: {}},
+			preds:  map[uint64][]uint64{0: {}, 4: {0}, 9: {0}},
+		},
+		{
+			// CALLDATALOAD JUMP JUMPDEST STOP, dynamic target
+			code:       "35565b00",
+			blocks:     []uint64{0, 2},
+			succs:      map[uint64][]uint64{0: {}, 2: {}},
+			unresolved: []uint64{0},
+		},
+		{
+			// PUSH1 2 JUMP, static target is not a JUMPDEST
+			code:   "60025600",
+			blocks: []uint64{0, 3},
+			succs:  map[uint64][]uint64{0: {}, 3: {}},
+		},
+		{
+			// PUSH1 0 JUMP, the entry block is no JUMPDEST
+			code:   "600056",
+			blocks: []uint64{0},
+			succs:  map[uint64][]uint64{0: {}},
+			preds:  map[uint64][]uint64{0: {}},
+		},
+		{
+			// PUSH1 1 PUSH1 5 JUMPI PUSH1 5 JUMP, the fall-through block is no JUMPDEST
+			code:   "6001600557600556",
+			blocks: []uint64{0, 5},
+			succs:  map[uint64][]uint64{0: {5}, 5: {}},
+			preds:  map[uint64][]uint64{0: {}, 5: {0}},
+		},
+		{
+			// PUSH1 0 POP JUMPDEST PUSH2 0x5b with truncated argument
+			code:   "6000505b615b",
+			blocks: []uint64{0, 3},
+			succs:  map[uint64][]uint64{0: {3}, 3: {}},
+		},
+		{
+			// PUSH1 0 POP JUMPDEST PUSH2 0x5b truncated by a metadata trailer
+			code:   "6000505b615b" + "a165627a7a72305820" + strings.Repeat("ef", 32) + "0029",
+			blocks: []uint64{0, 3},
+			succs:  map[uint64][]uint64{0: {3}, 3: {}},
+		},
+		{
+			// JUMPDEST inside push data is not a block start
+			code:   "605b5b00",
+			blocks: []uint64{0, 2},
+			succs:  map[uint64][]uint64{0: {2}},
+		},
+		{
+			code:   "",
+			blocks: []uint64{},
+		},
+	} {
+		code, _ := hex.DecodeString(tc.code)
+		cfg := NewCFG(code)
+		if have := starts(cfg.Blocks); !equal(have, tc.blocks) {
+			t.Errorf("test %d: wrong blocks, have %v want %v", i, have, tc.blocks)
+			continue
+		}
+		for start, want := range tc.succs {
+			if have := starts(cfg.Block(start).Successors()); !equal(have, want) {
+				t.Errorf("test %d: wrong successors of %d, have %v want %v", i, start, have, want)
+			}
+		}
+		for start, want := range tc.preds {
+			if have := starts(cfg.Block(start).Predecessors()); !equal(have, want) {
+				t.Errorf("test %d: wrong predecessors of %d, have %v want %v", i, start, have, want)
+			}
+		}
+		if have := starts(cfg.Unresolved); !equal(have, tc.unresolved) {
+			t.Errorf("test %d: wrong unresolved jumps, have %v want %v", i, have, tc.unresolved)
+		}
+	}
+}
+
+func TestCFGBlock(t *testing.T) {
+	// PUSH1 4 JUMP INVALID JUMPDEST STOP
+	code, _ := hex.DecodeString("600456fe5b00")
+	cfg := NewCFG(code)
+	for _, tc := range []struct {
+		pc    uint64
+		start uint64
+		found bool
+	}{
+		{0, 0, true}, {1, 0, true}, {2, 0, true}, {3, 3, true}, {5, 4, true}, {6, 0, false},
+	} {
+		b := cfg.Block(tc.pc)
+		if (b != nil) != tc.found || (b != nil && b.Start != tc.start) {
+			t.Errorf("pc %d: wrong block %v", tc.pc, b)
+		}
+	}
+	if last := cfg.Entry().Last(); last.Op != vm.JUMP || last.PC != 2 {
+		t.Errorf("wrong last instruction of entry %v", last)
+	}
+	if truncated := NewCFG([]byte{byte(vm.PUSH2), 0x5b}).Entry().Last(); len(truncated.Arg) != 2 || truncated.Arg[0] != 0x5b {
+		t.Errorf("wrong truncated push %x", truncated.Arg)
+	}
+
+	// the missing bytes of a push are not read from the metadata trailer
+	code, _ = hex.DecodeString("615b" + "a165627a7a72305820" + strings.Repeat("ef", 32) + "0029")
+	entry := NewCFG(code).Entry()
+	if truncated := entry.Last(); len(truncated.Arg) != 2 || truncated.Arg[0] != 0x5b || truncated.Arg[1] != 0 {
+		t.Errorf("wrong truncated push %x", truncated.Arg)
+	}
+	if entry.End != 2 {
+		t.Errorf("expected the entry to end at the trailer, got %d", entry.End)
+	}
+}
@@ -0,0 +1,68 @@
+package asm
+
+import (
+	"bytes"
+	"fadingrose/rosy-nigh/core/vm"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Dictionary holds the constants of a contract, deduplicated and in order of
+// appearance, for mutators to splice into inputs.
+type Dictionary struct {
+	// Constants are the non-zero arguments of every PUSH, without leading
+	// zeros
+	Constants [][]byte
+	// Selectors are the functions selectors of the dispatcher
+	Selectors [][4]byte
+	// Addresses are the 20 bytes constants which look like addresses
+	Addresses []common.Address
+}
+
+// addressMask is used to clean the upper bits of addresses, not an address
+var addressMask = bytes.Repeat([]byte{0xff}, common.AddressLength)
+
+// NewDictionary collects the constants of code.
+func NewDictionary(code []byte) *Dictionary {
+	var (
+		d         = new(Dictionary)
+		constants = make(map[string]bool)
+		addresses = make(map[common.Address]bool)
+	)
+	it := NewInstructionIterator(code)
+	for it.Next() {
+		if !it.Op().IsPush() || it.Op() == vm.PUSH0 {
+			continue
+		}
+		arg := bytes.TrimLeft(it.Arg(), "\x00")
+		if len(arg) == 0 {
+			// zero is no more interesting than PUSH0
+			continue
+		}
+		if !constants[string(arg)] {
+			constants[string(arg)] = true
+			d.Constants = append(d.Constants, arg)
+		}
+		if it.Op() == vm.PUSH20 && len(arg) > 4 && !bytes.Equal(arg, addressMask) {
+			addr := common.BytesToAddress(arg)
+			if !addresses[addr] {
+				addresses[addr] = true
+				d.Addresses = append(d.Addresses, addr)
+			}
+		}
+	}
+	for _, fn := range Selectors(code, nil) {
+		d.Selectors = append(d.Selectors, fn.Selector)
+	}
+	return d
+}
+
+// Words returns the constants left padded to 32 bytes, the way they appear
+// in ABI encoded arguments.
+func (d *Dictionary) Words() [][32]byte {
+	ret := make([][32]byte, 0, len(d.Constants))
+	for _, c := range d.Constants {
+		ret = append(ret, [32]byte(common.LeftPadBytes(c, 32)))
+	}
+	return ret
+}
@@ -0,0 +1,50 @@
+package asm
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDictionary(t *testing.T) {
+	code, err := Assemble(`
+PUSH0
+PUSH1 0x00
+PUSH2 0x0001
+PUSH1 0x01
+PUSH20 0xffffffffffffffffffffffffffffffffffffffff
+PUSH20 0x000000000000000000000000000000000000beef
+PUSH20 0xdAC17F958D2ee523a2206206994597C13D831ec7
+PUSH20 0xdAC17F958D2ee523a2206206994597C13D831ec7
+DUP1
+PUSH4 0xa9059cbb
+EQ
+PUSH @f
+JUMPI
+f:
+JUMPDEST`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewDictionary(code)
+
+	want := []string{"01", "ffffffffffffffffffffffffffffffffffffffff", "beef", "dac17f958d2ee523a2206206994597c13d831ec7", "a9059cbb", "66"}
+	if len(d.Constants) != len(want) {
+		t.Fatalf("wrong constants, have %x want %v", d.Constants, want)
+	}
+	for i, c := range d.Constants {
+		if hex.EncodeToString(c) != want[i] {
+			t.Errorf("constant %d: have %x want %s", i, c, want[i])
+		}
+	}
+	if len(d.Addresses) != 1 || d.Addresses[0] != common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7") {
+		t.Errorf("wrong addresses %v", d.Addresses)
+	}
+	if len(d.Selectors) != 1 || hex.EncodeToString(d.Selectors[0][:]) != "a9059cbb" {
+		t.Errorf("wrong selectors %x", d.Selectors)
+	}
+	if words := d.Words(); len(words) != len(want) || words[2][30] != 0xbe || words[2][31] != 0xef {
+		t.Errorf("wrong words %x", words)
+	}
+}
@@ -0,0 +1,127 @@
+package asm
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Coverage maps the start pc of basic blocks to the number of times a
+// coverage run entered them, blocks missing from it were never reached.
+type Coverage map[uint64]uint64
+
+// String returns the instruction in the format of Disassemble.
+func (ins Instruction) String() string {
+	if len(ins.Arg) > 0 {
+		return fmt.Sprintf("%05x: %v %#x", ins.PC, ins.Op, ins.Arg)
+	}
+	return fmt.Sprintf("%05x: %v", ins.PC, ins.Op)
+}
+
+// WriteDOT writes the graph in Graphviz DOT format. If cov is not nil each
+// block is annotated with its hit count and unreached blocks are
+// highlighted. Blocks ending with an unresolved jump have a dashed border.
+func (c *CFG) WriteDOT(w io.Writer, cov Coverage) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph cfg {")
+	fmt.Fprintln(bw, "\tnode [shape=box fontname=monospace];")
+
+	unresolved := make(map[*BasicBlock]bool, len(c.Unresolved))
+	for _, b := range c.Unresolved {
+		unresolved[b] = true
+	}
+	for _, b := range c.Blocks {
+		var label strings.Builder
+		fmt.Fprintf(&label, "%#x-%#x", b.Start, b.End)
+		if cov != nil {
+			fmt.Fprintf(&label, " hits: %d", cov[b.Start])
+		}
+		label.WriteString(`\l`)
+		for _, ins := range b.Instructions {
+			label.WriteString(dotEscape(ins.String()))
+			label.WriteString(`\l`)
+		}
+
+		var styles []string
+		attrs := fmt.Sprintf(`label="%s"`, label.String())
+		if unresolved[b] {
+			styles = append(styles, "dashed")
+		}
+		if cov != nil {
+			styles = append(styles, "filled")
+			if cov[b.Start] == 0 {
+				attrs += " fillcolor=lightpink"
+			} else {
+				attrs += " fillcolor=palegreen"
+			}
+		}
+		if len(styles) > 0 {
+			attrs += fmt.Sprintf(` style="%s"`, strings.Join(styles, ","))
+		}
+		fmt.Fprintf(bw, "\tb%d [%s];\n", b.Start, attrs)
+	}
+	for _, b := range c.Blocks {
+		for _, succ := range b.succs {
+			fmt.Fprintf(bw, "\tb%d -> b%d;\n", b.Start, succ.Start)
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+// jsonBlock is the JSON form of a basic block
+type jsonBlock struct {
+	Start        uint64   `json:"start"`
+	End          uint64   `json:"end"`
+	Instructions []string `json:"instructions"`
+	Successors   []uint64 `json:"successors"`
+	Predecessors []uint64 `json:"predecessors"`
+	Unresolved   bool     `json:"unresolved,omitempty"`
+	Hits         *uint64  `json:"hits,omitempty"`
+}
+
+// WriteJSON writes the graph as JSON, blocks reference each other by start
+// pc. If cov is not nil each block carries its hit count.
+func (c *CFG) WriteJSON(w io.Writer, cov Coverage) error {
+	unresolved := make(map[*BasicBlock]bool, len(c.Unresolved))
+	for _, b := range c.Unresolved {
+		unresolved[b] = true
+	}
+	out := struct {
+		Blocks []jsonBlock `json:"blocks"`
+	}{Blocks: make([]jsonBlock, 0, len(c.Blocks))}
+
+	for _, b := range c.Blocks {
+		jb := jsonBlock{
+			Start:        b.Start,
+			End:          b.End,
+			Instructions: make([]string, 0, len(b.Instructions)),
+			Successors:   make([]uint64, 0, len(b.succs)),
+			Predecessors: make([]uint64, 0, len(b.preds)),
+			Unresolved:   unresolved[b],
+		}
+		for _, ins := range b.Instructions {
+			jb.Instructions = append(jb.Instructions, ins.String())
+		}
+		for _, succ := range b.succs {
+			jb.Successors = append(jb.Successors, succ.Start)
+		}
+		for _, pred := range b.preds {
+			jb.Predecessors = append(jb.Predecessors, pred.Start)
+		}
+		if cov != nil {
+			hits := cov[b.Start]
+			jb.Hits = &hits
+		}
+		out.Blocks = append(out.Blocks, jb)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
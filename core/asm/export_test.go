@@ -0,0 +1,88 @@
+package asm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteDOT(t *testing.T) {
+	// CALLDATASIZE PUSH1 9 JUMPI PUSH1 0 PUSH1 0 REVERT JUMPDEST STOP
+	code, _ := hex.DecodeString("3660095760006000fd5b00")
+	cfg := NewCFG(code)
+
+	for _, tc := range []struct {
+		cov  Coverage
+		want []string
+	}{
+		{
+			cov: nil,
+			want: []string{
+				`b0 [label="0x0-0x4\l00000: CALLDATASIZE\l00001: PUSH1 0x09\l00003: JUMPI\l"];`,
+				`b0 -> b9;`,
+				`b0 -> b4;`,
+			},
+		},
+		{
+			cov: Coverage{0: 3, 9: 3},
+			want: []string{
+				`b0 [label="0x0-0x4 hits: 3\l`,
+				`b4 [label="0x4-0x9 hits: 0\l00004: PUSH1 0x00\l00006: PUSH1 0x00\l00008: REVERT\l" fillcolor=lightpink style="filled"];`,
+				`fillcolor=palegreen`,
+			},
+		},
+	} {
+		var buf bytes.Buffer
+		if err := cfg.WriteDOT(&buf, tc.cov); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		out := buf.String()
+		if !strings.HasPrefix(out, "digraph cfg {") || !strings.HasSuffix(out, "}\n") {
+			t.Errorf("malformed graph:\n%s", out)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("missing %q in:\n%s", want, out)
+			}
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	// CALLDATALOAD JUMP JUMPDEST STOP
+	code, _ := hex.DecodeString("35565b00")
+	cfg := NewCFG(code)
+
+	var buf bytes.Buffer
+	if err := cfg.WriteJSON(&buf, Coverage{0: 1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var out struct {
+		Blocks []jsonBlock `json:"blocks"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if len(out.Blocks) != 2 {
+		t.Fatalf("wrong block count, have %d want 2", len(out.Blocks))
+	}
+	entry, dest := out.Blocks[0], out.Blocks[1]
+	if entry.Start != 0 || entry.End != 2 || !entry.Unresolved || entry.Hits == nil || *entry.Hits != 1 {
+		t.Errorf("wrong entry block %+v", entry)
+	}
+	if len(entry.Instructions) != 2 || entry.Instructions[1] != "00001: JUMP" {
+		t.Errorf("wrong instructions %v", entry.Instructions)
+	}
+	if dest.Hits == nil || *dest.Hits != 0 || len(dest.Predecessors) != 0 {
+		t.Errorf("wrong jump destination %+v", dest)
+	}
+
+	// without coverage hits are left out
+	buf.Reset()
+	cfg.WriteJSON(&buf, nil)
+	if strings.Contains(buf.String(), "hits") {
+		t.Errorf("unexpected hits in:\n%s", buf.String())
+	}
+}
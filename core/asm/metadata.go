@@ -0,0 +1,198 @@
+package asm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Metadata is the CBOR encoded trailer solc appends to runtime code, see
+// https://docs.soliditylang.org/en/latest/metadata.html.
+type Metadata struct {
+	// IPFS is the multihash of the metadata file on IPFS
+	IPFS []byte
+	// Bzzr0 and Bzzr1 are the Swarm hashes used by older compilers
+	Bzzr0 []byte
+	Bzzr1 []byte
+	// Solc is the compiler version, like "0.8.24"
+	Solc string
+	// Experimental is set when experimental features were enabled
+	Experimental bool
+	// Length is the size of the trailer, including the 2 length bytes
+	Length int
+}
+
+// SplitMetadata splits code into the executable part and its metadata
+// trailer, meta is nil if code has no trailer.
+func SplitMetadata(code []byte) (runtime []byte, meta *Metadata) {
+	if len(code) < 2 {
+		return code, nil
+	}
+	size := int(binary.BigEndian.Uint16(code[len(code)-2:]))
+	if size == 0 || size+2 > len(code) {
+		return code, nil
+	}
+	start := len(code) - 2 - size
+	meta, err := decodeMetadata(code[start : len(code)-2])
+	if err != nil {
+		return code, nil
+	}
+	meta.Length = size + 2
+	return code[:start], meta
+}
+
+var errCBOR = errors.New("malformed cbor")
+
+// decodeMetadata decodes the CBOR map of the trailer, the whole input must
+// be consumed for it to be considered metadata.
+func decodeMetadata(data []byte) (*Metadata, error) {
+	d := &cborDecoder{data: data}
+	major, count, err := d.head()
+	if err != nil || major != cborMap || count == 0 {
+		return nil, errCBOR
+	}
+
+	meta := new(Metadata)
+	for i := uint64(0); i < count; i++ {
+		major, n, err := d.head()
+		if err != nil || major != cborText {
+			return nil, errCBOR
+		}
+		key, err := d.take(n)
+		if err != nil {
+			return nil, err
+		}
+		switch string(key) {
+		case "ipfs":
+			meta.IPFS, err = d.bytes()
+		case "bzzr0":
+			meta.Bzzr0, err = d.bytes()
+		case "bzzr1":
+			meta.Bzzr1, err = d.bytes()
+		case "solc":
+			meta.Solc, err = d.version()
+		case "experimental":
+			meta.Experimental, err = d.bool()
+		default:
+			err = d.skip()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(d.data) != 0 {
+		return nil, errCBOR
+	}
+	return meta, nil
+}
+
+// CBOR major types used by the trailer
+const (
+	cborUint   = 0
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborSimple = 7
+)
+
+// cborDecoder decodes the subset of CBOR emitted by compilers.
+type cborDecoder struct {
+	data []byte
+}
+
+func (d *cborDecoder) take(n uint64) ([]byte, error) {
+	if uint64(len(d.data)) < n {
+		return nil, errCBOR
+	}
+	ret := d.data[:n]
+	d.data = d.data[n:]
+	return ret, nil
+}
+
+// head reads an item header and returns its major type and argument.
+func (d *cborDecoder) head() (byte, uint64, error) {
+	b, err := d.take(1)
+	if err != nil {
+		return 0, 0, err
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info <= 27:
+		arg, err := d.take(1 << (info - 24))
+		if err != nil {
+			return 0, 0, err
+		}
+		var v uint64
+		for _, b := range arg {
+			v = v<<8 | uint64(b)
+		}
+		return major, v, nil
+	default:
+		// indefinite lengths are never emitted
+		return 0, 0, errCBOR
+	}
+}
+
+func (d *cborDecoder) bytes() ([]byte, error) {
+	major, n, err := d.head()
+	if err != nil || major != cborBytes {
+		return nil, errCBOR
+	}
+	return d.take(n)
+}
+
+func (d *cborDecoder) bool() (bool, error) {
+	major, v, err := d.head()
+	if err != nil || major != cborSimple || (v != 20 && v != 21) {
+		return false, errCBOR
+	}
+	return v == 21, nil
+}
+
+// version decodes the solc version, 3 bytes for releases and a text string
+// for prereleases.
+func (d *cborDecoder) version() (string, error) {
+	major, n, err := d.head()
+	if err != nil {
+		return "", err
+	}
+	raw, err := d.take(n)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case major == cborBytes && n == 3:
+		return fmt.Sprintf("%d.%d.%d", raw[0], raw[1], raw[2]), nil
+	case major == cborText:
+		return string(raw), nil
+	default:
+		return "", errCBOR
+	}
+}
+
+// skip steps over an item of unknown meaning.
+func (d *cborDecoder) skip() error {
+	major, n, err := d.head()
+	if err != nil {
+		return err
+	}
+	switch major {
+	case cborUint, cborSimple:
+		return nil
+	case cborBytes, cborText:
+		_, err = d.take(n)
+		return err
+	case cborArray:
+		for i := uint64(0); i < n; i++ {
+			if err := d.skip(); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return errCBOR
+	}
+}
@@ -0,0 +1,88 @@
+package asm
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// runtime is PUSH1 0x80 PUSH1 0x40 MSTORE INVALID
+const runtime = "6080604052fe"
+
+func TestSplitMetadata(t *testing.T) {
+	for i, tc := range []struct {
+		code string
+		want *Metadata
+	}{
+		{
+			// solc 0.8.24, ipfs
+			code: runtime + "a2646970667358221220" + strings.Repeat("ab", 32) + "64736f6c63430008180033",
+			want: &Metadata{IPFS: append([]byte{0x12, 0x20}, hexBytes(strings.Repeat("ab", 32))...), Solc: "0.8.24", Length: 53},
+		},
+		{
+			// solc 0.5.17, bzzr1
+			code: runtime + "a265627a7a72315820" + strings.Repeat("cd", 32) + "64736f6c63430005110032",
+			want: &Metadata{Bzzr1: hexBytes(strings.Repeat("cd", 32)), Solc: "0.5.17", Length: 52},
+		},
+		{
+			// old compiler, bzzr0 only
+			code: runtime + "a165627a7a72305820" + strings.Repeat("ef", 32) + "0029",
+			want: &Metadata{Bzzr0: hexBytes(strings.Repeat("ef", 32)), Length: 43},
+		},
+		{
+			// experimental, prerelease version as text
+			code: runtime + "a26c6578706572696d656e74616cf564736f6c6378" + "18" + hex.EncodeToString([]byte("0.8.25-nightly.2024.3.1+")) + "002e",
+			want: &Metadata{Experimental: true, Solc: "0.8.25-nightly.2024.3.1+", Length: 48},
+		},
+		{code: runtime},
+		{code: "00"},
+		{code: ""},
+		{code: runtime + "a1646970667358" + "0008"}, // truncated value
+		{code: runtime + "0003"},                    // not a map
+	} {
+		code := hexBytes(tc.code)
+		rest, meta := SplitMetadata(code)
+		if tc.want == nil {
+			if meta != nil || len(rest) != len(code) {
+				t.Errorf("test %d: unexpected metadata %+v", i, meta)
+			}
+			continue
+		}
+		if meta == nil {
+			t.Errorf("test %d: missing metadata", i)
+			continue
+		}
+		if hex.EncodeToString(rest) != runtime {
+			t.Errorf("test %d: wrong runtime code %x", i, rest)
+		}
+		if hex.EncodeToString(meta.IPFS) != hex.EncodeToString(tc.want.IPFS) ||
+			hex.EncodeToString(meta.Bzzr0) != hex.EncodeToString(tc.want.Bzzr0) ||
+			hex.EncodeToString(meta.Bzzr1) != hex.EncodeToString(tc.want.Bzzr1) ||
+			meta.Solc != tc.want.Solc || meta.Experimental != tc.want.Experimental || meta.Length != tc.want.Length {
+			t.Errorf("test %d: wrong metadata, have %+v want %+v", i, meta, tc.want)
+		}
+	}
+}
+
+// Tests that the trailer is not disassembled
+func TestDisassembleMetadata(t *testing.T) {
+	code := hexBytes(runtime + "a2646970667358221220" + strings.Repeat("ab", 32) + "64736f6c63430008180033")
+	listing, err := Disassemble(code)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(listing) != 4 {
+		t.Errorf("wrong instruction count, have %d want 4", len(listing))
+	}
+	if cfg := NewCFG(code); len(cfg.Blocks) != 1 || cfg.Blocks[0].End != 6 {
+		t.Errorf("metadata in cfg %+v", cfg.Blocks)
+	}
+}
+
+func hexBytes(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
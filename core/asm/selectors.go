@@ -0,0 +1,148 @@
+package asm
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fadingrose/rosy-nigh/core/vm"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Function is a public function recovered from the dispatcher of a
+// contract.
+type Function struct {
+	Selector [4]byte
+	// Entry is the pc the dispatcher transfers control to for Selector
+	Entry uint64
+	// Signature is the text signature found in the signature database, if any
+	Signature string
+}
+
+// SignatureDB maps selectors to text signatures like "transfer(address,uint256)".
+type SignatureDB map[[4]byte]string
+
+// Add registers signature under its selector.
+func (db SignatureDB) Add(signature string) {
+	var selector [4]byte
+	copy(selector[:], crypto.Keccak256([]byte(signature)))
+	db[selector] = signature
+}
+
+// LoadSignatures reads a signature database, one signature per line either
+// bare or prefixed with its hex selector, as in 4byte directory dumps.
+// Empty lines and lines starting with # are skipped.
+func LoadSignatures(r io.Reader) (SignatureDB, error) {
+	db := make(SignatureDB)
+	scanner := bufio.NewScanner(r)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 1:
+			db.Add(fields[0])
+		case 2:
+			raw, err := hex.DecodeString(strings.TrimPrefix(fields[0], "0x"))
+			if err != nil || len(raw) != 4 {
+				return nil, fmt.Errorf("line %d: invalid selector %q", lineno, fields[0])
+			}
+			db[[4]byte(raw)] = fields[1]
+		default:
+			return nil, fmt.Errorf("line %d: expected [selector] signature", lineno)
+		}
+	}
+	return db, scanner.Err()
+}
+
+// Selectors recovers the public functions of code from its dispatcher, in
+// the order they are dispatched. It recognizes the comparisons emitted by
+// Solidity, which jump to the function on a match:
+//
+//	DUP1 PUSH4 selector EQ PUSH dest JUMPI
+//
+// and by Vyper, which jump away on a mismatch and fall through to the
+// function:
+//
+//	PUSH4 selector DUP2 XOR PUSH next JUMPI
+//	PUSH4 selector PUSH1 0 MLOAD EQ ISZERO PUSH next JUMPI
+//
+// Signatures are looked up in db if it is not nil.
+func Selectors(code []byte, db SignatureDB) []Function {
+	var (
+		cfg  = NewCFG(code)
+		seen = make(map[[4]byte]bool)
+		ret  []Function
+	)
+	for _, b := range cfg.Blocks {
+		for i, ins := range b.Instructions {
+			if ins.Op != vm.PUSH4 {
+				continue
+			}
+			entry, ok := dispatchEntry(cfg, b, b.Instructions[i+1:])
+			if !ok {
+				continue
+			}
+			selector := [4]byte(ins.Arg)
+			if seen[selector] {
+				continue
+			}
+			seen[selector] = true
+			fn := Function{Selector: selector, Entry: entry}
+			if db != nil {
+				fn.Signature = db[selector]
+			}
+			ret = append(ret, fn)
+		}
+	}
+	return ret
+}
+
+// dispatchEntry matches the rest of a dispatcher comparison following a
+// PUSH4 in b and returns the entry of the function.
+func dispatchEntry(cfg *CFG, b *BasicBlock, rest []Instruction) (uint64, bool) {
+	// skip loading the calldata selector, at most a few instructions
+	i := 0
+	for ; i < len(rest) && i < 3; i++ {
+		op := rest[i].Op
+		if !(vm.DUP1 <= op && op <= vm.DUP16) && op != vm.PUSH1 && op != vm.MLOAD && op != vm.CALLDATALOAD {
+			break
+		}
+	}
+	rest = rest[i:]
+
+	var fallsThrough bool
+	switch {
+	case len(rest) == 3 && rest[0].Op == vm.EQ:
+	case len(rest) == 3 && rest[0].Op == vm.XOR:
+		fallsThrough = true
+	case len(rest) == 4 && rest[0].Op == vm.EQ && rest[1].Op == vm.ISZERO:
+		fallsThrough, rest = true, rest[1:]
+	default:
+		return 0, false
+	}
+	// the JUMPI ends the block, so the comparison is the tail of b
+	if !rest[1].Op.IsPush() || rest[2].Op != vm.JUMPI {
+		return 0, false
+	}
+
+	if fallsThrough {
+		if next := cfg.Block(b.End); next != nil {
+			return next.Start, true
+		}
+		return 0, false
+	}
+	dest := new(big.Int).SetBytes(rest[1].Arg)
+	if !dest.IsUint64() {
+		return 0, false
+	}
+	if target := cfg.byStart[dest.Uint64()]; target != nil && target.Instructions[0].Op == vm.JUMPDEST {
+		return target.Start, true
+	}
+	return 0, false
+}
@@ -0,0 +1,122 @@
+package asm
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestSelectors(t *testing.T) {
+	for i, tc := range []struct {
+		src  string
+		want map[string]uint64 // selector to entry pc
+	}{
+		{
+			// solidity
+			src: `
+PUSH1 0x00
+CALLDATALOAD
+PUSH1 0xe0
+SHR
+DUP1
+PUSH4 0xa9059cbb
+EQ
+PUSH @transfer
+JUMPI
+DUP1
+PUSH4 0x70a08231
+EQ
+PUSH @balanceOf
+JUMPI
+PUSH1 0x00
+DUP1
+REVERT
+transfer:
+JUMPDEST
+STOP
+balanceOf:
+JUMPDEST
+STOP`,
+			want: map[string]uint64{"a9059cbb": 30, "70a08231": 32},
+		},
+		{
+			// vyper, functions follow the comparison
+			src: `
+PUSH4 0x18160ddd
+DUP2
+XOR
+PUSH @next
+JUMPI
+totalSupply:
+PUSH1 0x00
+SLOAD
+STOP
+next:
+JUMPDEST
+PUSH4 0x06fdde03
+PUSH1 0x00
+MLOAD
+EQ
+ISZERO
+PUSH @end
+JUMPI
+name:
+STOP
+end:
+JUMPDEST
+STOP`,
+			want: map[string]uint64{"18160ddd": 10, "06fdde03": 28},
+		},
+		{
+			// comparisons against constants and jumps to non JUMPDEST
+			src: `
+DUP1
+PUSH4 0xdeadbeef
+EQ
+PUSH @nowhere
+JUMPI
+PUSH4 0x12345678
+PUSH1 0x01
+ADD
+nowhere:
+STOP`,
+			want: map[string]uint64{},
+		},
+	} {
+		code, err := Assemble(tc.src)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		have := Selectors(code, nil)
+		if len(have) != len(tc.want) {
+			t.Errorf("test %d: wrong functions, have %v want %v", i, have, tc.want)
+			continue
+		}
+		for _, fn := range have {
+			entry, ok := tc.want[hex.EncodeToString(fn.Selector[:])]
+			if !ok || entry != fn.Entry {
+				t.Errorf("test %d: unexpected function %x at %d", i, fn.Selector, fn.Entry)
+			}
+		}
+	}
+}
+
+func TestSignatureDB(t *testing.T) {
+	db, err := LoadSignatures(strings.NewReader(`
+# a comment
+transfer(address,uint256)
+0x70a08231 balanceOf(address)
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	code, _ := Assemble("DUP1\nPUSH4 0xa9059cbb\nEQ\nPUSH @f\nJUMPI\nDUP1\nPUSH4 0x70a08231\nEQ\nPUSH @f\nJUMPI\nf:\nJUMPDEST")
+	fns := Selectors(code, db)
+	if len(fns) != 2 || fns[0].Signature != "transfer(address,uint256)" || fns[1].Signature != "balanceOf(address)" {
+		t.Errorf("wrong signatures %v", fns)
+	}
+
+	if _, err := LoadSignatures(strings.NewReader("0x1234 foo()")); err == nil || err.Error() != `line 1: invalid selector "0x1234"` {
+		t.Errorf("expected invalid selector error, got %v", err)
+	}
+}
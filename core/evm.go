@@ -1,8 +1,12 @@
 package core
 
 import (
+	"fadingrose/rosy-nigh/core/tracing"
 	"fadingrose/rosy-nigh/core/vm"
 	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
 )
 
 // NewEVMTxContext creates a new transaction context for a single transaction.
@@ -17,3 +21,15 @@ func NewEVMTxContext(msg *Message) vm.TxContext {
 	}
 	return ctx
 }
+
+// CanTransfer checks whether there are enough funds in the address' account to make a transfer.
+// This does not take the necessary gas in to account to make the transfer valid.
+func CanTransfer(db vm.StateDB, addr common.Address, amount *uint256.Int) bool {
+	return db.GetBalance(addr).Cmp(amount) >= 0
+}
+
+// Transfer subtracts amount from sender and adds amount to recipient using the given Db
+func Transfer(db vm.StateDB, sender, recipient common.Address, amount *uint256.Int) {
+	db.SubBalance(sender, amount, tracing.BalanceChangeTransfer)
+	db.AddBalance(recipient, amount, tracing.BalanceChangeTransfer)
+}
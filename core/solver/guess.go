@@ -0,0 +1,235 @@
+package solver
+
+import (
+	"fadingrose/rosy-nigh/core/vm"
+
+	"github.com/holiman/uint256"
+)
+
+// Guess is a pure-Go solver for the simple constraints guarding most hard
+// branches, like require(x == 0xdeadbeef). It tries the constants of the
+// constraints, their neighbours and the constants left aligned as the value
+// of each input, and keeps the changes satisfying more constraints. It is
+// incomplete: it never returns ErrUnsat, only ErrUnknown when it finds no
+// model.
+type Guess struct{}
+
+// guessInput is a word of the inputs a Guess assigns at once
+type guessInput struct {
+	calldata  bool
+	offset    uint64
+	callvalue bool
+}
+
+// guessModel is a candidate assignment of the inputs
+type guessModel struct {
+	calldata  map[uint64]byte
+	callvalue uint256.Int
+}
+
+func (m *guessModel) get(in guessInput) uint256.Int {
+	if in.callvalue {
+		return m.callvalue
+	}
+	var word [32]byte
+	for i := range word {
+		word[i] = m.calldata[in.offset+uint64(i)]
+	}
+	return *new(uint256.Int).SetBytes32(word[:])
+}
+
+func (m *guessModel) set(in guessInput, v uint256.Int) {
+	if in.callvalue {
+		m.callvalue = v
+		return
+	}
+	word := v.Bytes32()
+	for i, b := range word {
+		m.calldata[in.offset+uint64(i)] = b
+	}
+}
+
+// Solve implements Solver.
+func (Guess) Solve(cs []Constraint) (*Model, error) {
+	roots := make([]*vm.Expr, len(cs))
+	for i, c := range cs {
+		roots[i] = c.Cond
+	}
+	var (
+		order      []*vm.Expr
+		inputs     []guessInput
+		seen       = make(map[guessInput]bool)
+		candidates = []uint256.Int{{}}
+		model      = &guessModel{calldata: make(map[uint64]byte)}
+	)
+	vm.WalkExprs(roots, func(e *vm.Expr) {
+		order = append(order, e)
+		var in guessInput
+		switch offset, ok := calldataOffset(e); {
+		case e.Kind == vm.ExprConst:
+			var prev, next, aligned uint256.Int
+			prev.SubUint64(&e.Value, 1)
+			next.AddUint64(&e.Value, 1)
+			// left aligned like selectors and bytesN in calldata
+			aligned.Lsh(&e.Value, uint(256-8*e.Value.ByteLen()))
+			candidates = append(candidates, e.Value, prev, next, aligned)
+			return
+		case ok:
+			in = guessInput{calldata: true, offset: offset}
+		case isCallValue(e):
+			in = guessInput{callvalue: true}
+		default:
+			return
+		}
+		if !seen[in] {
+			seen[in] = true
+			inputs = append(inputs, in)
+			// start from the execution the constraints were collected on
+			model.set(in, e.Value)
+		}
+	})
+
+	goal := (len(cs) + 1) * len(cs)
+	best := model.score(cs, order)
+	for improved := true; improved && best < goal; {
+		improved = false
+		for _, in := range inputs {
+			current := model.get(in)
+			for _, v := range candidates {
+				model.set(in, v)
+				if score := model.score(cs, order); score > best {
+					best, current, improved = score, v, true
+				}
+			}
+			model.set(in, current)
+		}
+	}
+	if best < goal {
+		return nil, ErrUnknown
+	}
+
+	ret := &Model{Calldata: model.calldata}
+	if seen[guessInput{callvalue: true}] {
+		ret.CallValue = &model.callvalue
+	}
+	return ret, nil
+}
+
+// score ranks how close m is to satisfying cs, by the number of leading
+// constraints it satisfies then by the number of constraints it satisfies.
+// Path constraints are ordered, an input reaching deeper into the path is
+// better. order lists the nodes of the constraints with operands first.
+func (m *guessModel) score(cs []Constraint, order []*vm.Expr) int {
+	values := make(map[*vm.Expr]*uint256.Int, len(order))
+	for _, e := range order {
+		values[e] = m.eval(e, values)
+	}
+	prefix, total := 0, 0
+	for i, c := range cs {
+		if values[c.Cond].IsZero() != c.Holds {
+			total++
+			if prefix == i {
+				prefix++
+			}
+		}
+	}
+	return prefix*len(cs) + total
+}
+
+// eval computes the value of e under m like the EVM, the values of its
+// operands are already in values. Variables which are no input and
+// operations without a known semantic keep their observed value.
+func (m *guessModel) eval(e *vm.Expr, values map[*vm.Expr]*uint256.Int) *uint256.Int {
+	z := new(uint256.Int)
+	switch e.Kind {
+	case vm.ExprConst:
+		return z.Set(&e.Value)
+	case vm.ExprVar:
+		if offset, ok := calldataOffset(e); ok {
+			*z = m.get(guessInput{calldata: true, offset: offset})
+		} else if isCallValue(e) {
+			z.Set(&m.callvalue)
+		} else {
+			z.Set(&e.Value)
+		}
+		return z
+	case vm.ExprOpaque:
+		return z.Set(&e.Value)
+	}
+
+	args := make([]*uint256.Int, len(e.Args))
+	for i, arg := range e.Args {
+		args[i] = values[arg]
+	}
+	switch e.Op {
+	case vm.ADD:
+		z.Add(args[0], args[1])
+	case vm.MUL:
+		z.Mul(args[0], args[1])
+	case vm.SUB:
+		z.Sub(args[0], args[1])
+	case vm.DIV:
+		z.Div(args[0], args[1])
+	case vm.SDIV:
+		z.SDiv(args[0], args[1])
+	case vm.MOD:
+		z.Mod(args[0], args[1])
+	case vm.SMOD:
+		z.SMod(args[0], args[1])
+	case vm.ADDMOD:
+		z.AddMod(args[0], args[1], args[2])
+	case vm.MULMOD:
+		z.MulMod(args[0], args[1], args[2])
+	case vm.EXP:
+		z.Exp(args[0], args[1])
+	case vm.SIGNEXTEND:
+		z.ExtendSign(args[1], args[0])
+	case vm.LT:
+		z.SetUint64(b2u(args[0].Lt(args[1])))
+	case vm.GT:
+		z.SetUint64(b2u(args[0].Gt(args[1])))
+	case vm.SLT:
+		z.SetUint64(b2u(args[0].Slt(args[1])))
+	case vm.SGT:
+		z.SetUint64(b2u(args[0].Sgt(args[1])))
+	case vm.EQ:
+		z.SetUint64(b2u(args[0].Eq(args[1])))
+	case vm.ISZERO:
+		z.SetUint64(b2u(args[0].IsZero()))
+	case vm.AND:
+		z.And(args[0], args[1])
+	case vm.OR:
+		z.Or(args[0], args[1])
+	case vm.XOR:
+		z.Xor(args[0], args[1])
+	case vm.NOT:
+		z.Not(args[0])
+	case vm.BYTE:
+		z.Set(args[1]).Byte(args[0])
+	case vm.SHL:
+		if args[0].LtUint64(256) {
+			z.Lsh(args[1], uint(args[0].Uint64()))
+		}
+	case vm.SHR:
+		if args[0].LtUint64(256) {
+			z.Rsh(args[1], uint(args[0].Uint64()))
+		}
+	case vm.SAR:
+		switch {
+		case args[0].LtUint64(256):
+			z.SRsh(args[1], uint(args[0].Uint64()))
+		case args[1].Sign() < 0:
+			z.SetAllOne()
+		}
+	default:
+		z.Set(&e.Value)
+	}
+	return z
+}
+
+func b2u(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,101 @@
+package solver
+
+import (
+	"errors"
+	"fadingrose/rosy-nigh/core/vm"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+func constant(v uint64) *vm.Expr {
+	return &vm.Expr{Kind: vm.ExprConst, Value: *uint256.NewInt(v)}
+}
+
+func calldata(offset uint64) *vm.Expr {
+	return &vm.Expr{Kind: vm.ExprVar, Op: vm.CALLDATALOAD, Args: []*vm.Expr{constant(offset)}, Depth: txDepth}
+}
+
+func callvalue() *vm.Expr {
+	return &vm.Expr{Kind: vm.ExprVar, Op: vm.CALLVALUE, Depth: txDepth}
+}
+
+func op(code vm.OpCode, args ...*vm.Expr) *vm.Expr {
+	return &vm.Expr{Kind: vm.ExprOp, Op: code, Args: args}
+}
+
+// satisfies reports whether m satisfies all of cs.
+func satisfies(m *Model, cs []Constraint) bool {
+	gm := &guessModel{calldata: m.Calldata}
+	if m.CallValue != nil {
+		gm.callvalue = *m.CallValue
+	}
+	roots := make([]*vm.Expr, len(cs))
+	for i, c := range cs {
+		roots[i] = c.Cond
+	}
+	var order []*vm.Expr
+	vm.WalkExprs(roots, func(e *vm.Expr) { order = append(order, e) })
+	return gm.score(cs, order) == (len(cs)+1)*len(cs)
+}
+
+func TestGuess(t *testing.T) {
+	x := calldata(4)
+	selector := op(vm.SHR, constant(224), calldata(0))
+	tcs := []struct {
+		name string
+		cs   []Constraint
+	}{
+		{"magic", []Constraint{{op(vm.EQ, constant(0xdeadbeef), x), true}}},
+		{"not magic", []Constraint{{op(vm.EQ, constant(0), x), false}}},
+		{"selector", []Constraint{{op(vm.EQ, constant(0xa9059cbb), selector), true}}},
+		{"selector and argument", []Constraint{
+			{op(vm.EQ, constant(0xa9059cbb), selector), true},
+			{op(vm.GT, x, constant(1000)), true},
+		}},
+		{"callvalue", []Constraint{{op(vm.ISZERO, op(vm.LT, callvalue(), constant(100))), true}}},
+		{"offset", []Constraint{{op(vm.EQ, op(vm.ADD, constant(1), x), constant(42)), true}}},
+	}
+	for _, tc := range tcs {
+		m, err := Guess{}.Solve(tc.cs)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if !satisfies(m, tc.cs) {
+			t.Errorf("%s: model %v does not satisfy the constraints", tc.name, m)
+		}
+	}
+}
+
+func TestGuessModel(t *testing.T) {
+	cs := []Constraint{{op(vm.EQ, constant(0xdeadbeef), calldata(4)), true}}
+	m, err := Guess{}.Solve(cs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	input := m.Input([]byte{0xa9, 0x05, 0x9c, 0xbb})
+	if len(input) != 36 {
+		t.Fatalf("expected 36 bytes, got %d", len(input))
+	}
+	if have := new(uint256.Int).SetBytes(input[4:]); have.Uint64() != 0xdeadbeef {
+		t.Errorf("expected 0xdeadbeef, got %v", have.Hex())
+	}
+	if input[0] != 0xa9 {
+		t.Errorf("expected the selector to be kept, got %x", input[:4])
+	}
+	if m.CallValue != nil {
+		t.Errorf("expected no callvalue, got %v", m.CallValue)
+	}
+}
+
+func TestGuessUnknown(t *testing.T) {
+	x := calldata(4)
+	cs := []Constraint{
+		{op(vm.EQ, constant(1), x), true},
+		{op(vm.EQ, constant(2), x), true},
+	}
+	if _, err := (Guess{}).Solve(cs); !errors.Is(err, ErrUnknown) {
+		t.Errorf("expected %v, got %v", ErrUnknown, err)
+	}
+}
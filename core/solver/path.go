@@ -0,0 +1,27 @@
+package solver
+
+import "fadingrose/rosy-nigh/core/vm"
+
+// PathConstraints returns the constraints of a path recorded in concolic
+// mode, one per branch in the same order, with a JUMPI taken requiring its
+// condition to hold.
+func PathConstraints(path []vm.Branch) []Constraint {
+	b := vm.NewExprBuilder()
+	cs := make([]Constraint, len(path))
+	for i, branch := range path {
+		cs[i] = Constraint{Cond: b.Build(branch.Cond), Holds: branch.Taken}
+	}
+	return cs
+}
+
+// Negate returns a copy of cs with the last n constraints negated. A model
+// of Negate(cs[:i+1], 1) follows the path up to branch i and takes the
+// other side there.
+func Negate(cs []Constraint, n int) []Constraint {
+	ret := make([]Constraint, len(cs))
+	copy(ret, cs)
+	for i := max(0, len(ret)-n); i < len(ret); i++ {
+		ret[i].Holds = !ret[i].Holds
+	}
+	return ret
+}
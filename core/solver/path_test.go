@@ -0,0 +1,113 @@
+package solver
+
+import (
+	"errors"
+	"fadingrose/rosy-nigh/core/vm"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// runPath runs code in concolic mode and returns the branches it took.
+func runPath(t *testing.T, pool vm.RegPoolConfig, code []byte, input []byte, value *uint256.Int) []vm.Branch {
+	random := common.Hash{}
+	blockCtx := vm.BlockContext{BlockNumber: big.NewInt(1), Random: &random}
+	evm := vm.NewEVM(blockCtx, vm.TxContext{}, nil, params.MergedTestChainConfig, vm.Config{Concolic: true, RegPool: pool})
+
+	caller := vm.AccountRef(common.HexToAddress("0xc0ffee"))
+	contract := vm.NewContract(caller, vm.AccountRef(common.HexToAddress("0xc0de")), value, 100000)
+	contract.Code = code
+	if _, err := evm.Interpreter().Run(contract, input, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return evm.Path()
+}
+
+func TestFlipBranch(t *testing.T) {
+	// PUSH1 4; CALLDATALOAD; PUSH4 0xdeadbeef; EQ; PUSH1 0x14; JUMPI;
+	// CALLVALUE; PUSH1 0x14; JUMPI; STOP; ... JUMPDEST
+	code := hexutil.MustDecode("0x60043563deadbeef1460145734601457000000005b")
+	input := hexutil.MustDecode("0xa9059cbb")
+
+	path := runPath(t, vm.RegPoolConfig{}, code, input, new(uint256.Int))
+	if len(path) != 2 || path[0].Taken || path[1].Taken {
+		t.Fatalf("unexpected path %+v", path)
+	}
+	cs := PathConstraints(path)
+	if have, want := cs[0].Cond.String(), "EQ(0xdeadbeef, CALLDATALOAD(0x4))"; have != want {
+		t.Errorf("expected %s, got %s", want, have)
+	}
+
+	// keep the first branch and flip the second
+	m, err := Guess{}.Solve(Negate(cs, 1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.CallValue == nil || m.CallValue.IsZero() {
+		t.Fatalf("expected a callvalue, got %v", m.CallValue)
+	}
+	path = runPath(t, vm.RegPoolConfig{}, code, m.Input(input), m.CallValue)
+	if len(path) != 2 || path[0].Taken || !path[1].Taken {
+		t.Errorf("expected to take the second branch, got %+v", path)
+	}
+
+	// flip the first branch
+	m, err = Guess{}.Solve(Negate(cs[:1], 1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	path = runPath(t, vm.RegPoolConfig{}, code, m.Input(input), new(uint256.Int))
+	if len(path) != 1 || !path[0].Taken {
+		t.Errorf("expected to take the first branch, got %+v", path)
+	}
+}
+
+func TestForgottenBranch(t *testing.T) {
+	// PUSH1 0; CALLDATALOAD; JUMPDEST x4; PUSH1 0x0b; JUMPI; JUMPDEST; JUMPDEST
+	code := hexutil.MustDecode("0x6000355b5b5b5b600b575b5b")
+
+	// the ring wrapped before the JUMPI, CALLDATALOAD lost its operand
+	path := runPath(t, vm.RegPoolConfig{Retain: vm.RetainRecent, RingSize: 2}, code, nil, new(uint256.Int))
+	if len(path) != 1 || path[0].Taken {
+		t.Fatalf("unexpected path %+v", path)
+	}
+	cs := Negate(PathConstraints(path), 1)
+	if cond := cs[0].Cond; cond.Kind != vm.ExprOpaque || cond.Op != vm.CALLDATALOAD {
+		t.Fatalf("expected an opaque CALLDATALOAD, got %v", cond)
+	}
+
+	// the condition keeps its observed value, flipping it is impossible
+	if _, err := (Guess{}).Solve(cs); !errors.Is(err, ErrUnknown) {
+		t.Errorf("expected %v, got %v", ErrUnknown, err)
+	}
+	if script := Script(cs); strings.Contains(script, "declare-const") {
+		t.Errorf("expected no input in\n%s", script)
+	}
+}
+
+func TestNegate(t *testing.T) {
+	cs := []Constraint{{constant(1), true}, {constant(2), true}, {constant(3), false}}
+	for n, want := range [][]bool{
+		{true, true, false},
+		{true, true, true},
+		{true, false, true},
+		{false, false, true},
+		{false, false, true},
+	} {
+		negated := Negate(cs, n)
+		for i, c := range negated {
+			if c.Holds != want[i] || c.Cond != cs[i].Cond {
+				t.Errorf("n=%d: expected %v, got %+v", n, want, negated)
+				break
+			}
+		}
+	}
+	if cs[2].Holds {
+		t.Errorf("Negate modified its input")
+	}
+}
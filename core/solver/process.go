@@ -0,0 +1,192 @@
+package solver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/holiman/uint256"
+)
+
+// ErrNoSolver is returned by FindSolver when no solver binary is installed
+var ErrNoSolver = errors.New("no smt solver found")
+
+// Process solves constraints with an external SMT-LIB2 solver, the script is
+// written to its stdin.
+type Process struct {
+	// Path is the solver binary, looked up in PATH if it has no separator
+	Path string
+	// Args make the solver read SMT-LIB2 from stdin
+	Args []string
+	// Timeout bounds a single Solve, zero means no limit
+	Timeout time.Duration
+}
+
+// knownSolvers are tried in order by FindSolver
+var knownSolvers = []Process{
+	{Path: "z3", Args: []string{"-in", "-smt2"}},
+	{Path: "bitwuzla", Args: []string{"--lang", "smt2"}},
+	{Path: "cvc5", Args: []string{"--lang", "smt2"}},
+}
+
+// FindSolver returns the first of z3, bitwuzla and cvc5 installed.
+func FindSolver() (*Process, error) {
+	for _, p := range knownSolvers {
+		if path, err := exec.LookPath(p.Path); err == nil {
+			return &Process{Path: path, Args: p.Args}, nil
+		}
+	}
+	return nil, ErrNoSolver
+}
+
+// Solve runs the solver on the script of cs.
+func (p *Process) Solve(cs []Constraint) (*Model, error) {
+	ctx := context.Background()
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Path, p.Args...)
+	cmd.Stdin = strings.NewReader(Script(cs))
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnknown, ctx.Err())
+	}
+	// solvers exit with an error on unsat or when asked for a model of an
+	// unsat script, the output tells what happened
+	result, rest, _ := strings.Cut(strings.TrimSpace(stdout.String()), "\n")
+	switch strings.TrimSpace(result) {
+	case "sat":
+		return parseModel(rest)
+	case "unsat":
+		return nil, ErrUnsat
+	case "unknown":
+		return nil, ErrUnknown
+	}
+	if err == nil {
+		err = errors.New("unexpected output")
+	}
+	return nil, fmt.Errorf("%s: %w: %s%s", p.Path, err, stdout.String(), stderr.String())
+}
+
+// parseModel parses the answer to get-value, like
+// ((cd4 #xde) (callvalue (_ bv0 256))).
+func parseModel(out string) (*Model, error) {
+	m := &Model{Calldata: make(map[uint64]byte)}
+	if strings.TrimSpace(out) == "" {
+		return m, nil
+	}
+	list, err := parseSExpr(out)
+	if err != nil {
+		return nil, err
+	}
+	pairs, ok := list.([]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid model %q", out)
+	}
+	for _, pair := range pairs {
+		pair, ok := pair.([]any)
+		if !ok || len(pair) != 2 {
+			return nil, fmt.Errorf("invalid model %q", out)
+		}
+		name, ok := pair[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid model %q", out)
+		}
+		value, err := parseBitVec(pair[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		switch {
+		case name == "callvalue":
+			m.CallValue = value
+		case strings.HasPrefix(name, "cd"):
+			offset, err := strconv.ParseUint(name[2:], 10, 64)
+			if err != nil || !value.LtUint64(256) {
+				return nil, fmt.Errorf("invalid calldata byte %s = %v", name, value.Hex())
+			}
+			m.Calldata[offset] = byte(value.Uint64())
+		default:
+			return nil, fmt.Errorf("unknown variable %s", name)
+		}
+	}
+	return m, nil
+}
+
+// parseBitVec parses a bitvector literal in any of the forms solvers print.
+func parseBitVec(v any) (*uint256.Int, error) {
+	var (
+		digits string
+		base   int
+	)
+	switch v := v.(type) {
+	case string:
+		switch {
+		case strings.HasPrefix(v, "#x"):
+			digits, base = v[2:], 16
+		case strings.HasPrefix(v, "#b"):
+			digits, base = v[2:], 2
+		}
+	case []any:
+		// (_ bvN width)
+		if len(v) == 3 && v[0] == "_" {
+			if s, ok := v[1].(string); ok && strings.HasPrefix(s, "bv") {
+				digits, base = s[2:], 10
+			}
+		}
+	}
+	if base == 0 {
+		return nil, fmt.Errorf("invalid bitvector %v", v)
+	}
+	value, ok := new(big.Int).SetString(digits, base)
+	if !ok || value.BitLen() > 256 {
+		return nil, fmt.Errorf("invalid bitvector %v", v)
+	}
+	return uint256.MustFromBig(value), nil
+}
+
+// parseSExpr parses a single s-expression into strings and nested []any.
+func parseSExpr(s string) (any, error) {
+	tokens := strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(s))
+	var stack [][]any
+	var ret any
+	for i, tok := range tokens {
+		switch tok {
+		case "(":
+			stack = append(stack, []any{})
+		case ")":
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("unbalanced %q", s)
+			}
+			list := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				ret = list
+				if i != len(tokens)-1 {
+					return nil, fmt.Errorf("trailing data in %q", s)
+				}
+				continue
+			}
+			stack[len(stack)-1] = append(stack[len(stack)-1], list)
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("expected a list in %q", s)
+			}
+			stack[len(stack)-1] = append(stack[len(stack)-1], tok)
+		}
+	}
+	if len(stack) != 0 || ret == nil {
+		return nil, fmt.Errorf("unbalanced %q", s)
+	}
+	return ret, nil
+}
@@ -0,0 +1,120 @@
+package solver
+
+import (
+	"errors"
+	"fadingrose/rosy-nigh/core/vm"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestHelperSolver is not a real test, it stands in for a solver binary
+// printing SOLVER_HELPER_OUTPUT.
+func TestHelperSolver(t *testing.T) {
+	out, ok := os.LookupEnv("SOLVER_HELPER_OUTPUT")
+	if !ok {
+		return
+	}
+	io.Copy(io.Discard, os.Stdin)
+	fmt.Print(out)
+	os.Exit(0)
+}
+
+func helperSolver(t *testing.T, out string) *Process {
+	t.Setenv("SOLVER_HELPER_OUTPUT", out)
+	return &Process{Path: os.Args[0], Args: []string{"-test.run=^TestHelperSolver$"}}
+}
+
+func TestProcess(t *testing.T) {
+	cs := []Constraint{{op(vm.EQ, constant(0xdeadbeef), calldata(4)), true}}
+	tcs := []struct {
+		name      string
+		out       string
+		calldata  map[uint64]byte
+		callvalue uint64
+		err       error
+	}{
+		{"z3", "sat\n((cd4 #xde) (callvalue #x000000000000000000000000000000000000000000000000000000000000002a))\n",
+			map[uint64]byte{4: 0xde}, 42, nil},
+		{"bitwuzla", "sat\n(\n  (cd4 #b11011110)\n  (callvalue #b101010)\n)\n",
+			map[uint64]byte{4: 0xde}, 42, nil},
+		{"cvc5", "sat\n((cd4 (_ bv222 8)) (callvalue (_ bv42 256)))\n",
+			map[uint64]byte{4: 0xde}, 42, nil},
+		{"unsat", "unsat\n(error \"model is not available\")\n", nil, 0, ErrUnsat},
+		{"unknown", "unknown\n", nil, 0, ErrUnknown},
+	}
+	for _, tc := range tcs {
+		m, err := helperSolver(t, tc.out).Solve(cs)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if len(m.Calldata) != len(tc.calldata) || m.Calldata[4] != tc.calldata[4] {
+			t.Errorf("%s: expected calldata %v, got %v", tc.name, tc.calldata, m.Calldata)
+		}
+		if m.CallValue == nil || m.CallValue.Uint64() != tc.callvalue {
+			t.Errorf("%s: expected callvalue %d, got %v", tc.name, tc.callvalue, m.CallValue)
+		}
+	}
+}
+
+func TestProcessErrors(t *testing.T) {
+	cs := []Constraint{{op(vm.ISZERO, callvalue()), true}}
+	for _, out := range []string{
+		"(error \"line 3: unknown constant\")\n",
+		"sat\n((cd4 #x1234))\n",
+		"sat\n((x #x00))\n",
+		"sat\n((cd4 #xde)\n",
+	} {
+		if _, err := helperSolver(t, out).Solve(cs); err == nil || errors.Is(err, ErrUnsat) || errors.Is(err, ErrUnknown) {
+			t.Errorf("expected an error for %q, got %v", out, err)
+		}
+	}
+}
+
+// TestProcessSolver runs an installed solver and checks its models with the
+// evaluator of Guess.
+func TestProcessSolver(t *testing.T) {
+	p, err := FindSolver()
+	if errors.Is(err, ErrNoSolver) {
+		t.Skip("no solver installed")
+	}
+	p.Timeout = 10 * time.Second
+
+	x := calldata(4)
+	selector := op(vm.SHR, constant(224), calldata(0))
+	tcs := []struct {
+		name string
+		cs   []Constraint
+	}{
+		{"magic", []Constraint{{op(vm.EQ, constant(0xdeadbeef), x), true}}},
+		{"selector and argument", []Constraint{
+			{op(vm.EQ, constant(0xa9059cbb), selector), true},
+			{op(vm.GT, x, constant(1000)), true},
+		}},
+		{"hash like", []Constraint{{op(vm.EQ, op(vm.MULMOD, x, x, constant(1000003)), constant(4)), true}}},
+		{"exp", []Constraint{{op(vm.EQ, op(vm.EXP, x, constant(3)), constant(27)), true}}},
+		{"signed", []Constraint{{op(vm.SLT, x, constant(0)), true}, {op(vm.SGT, op(vm.SDIV, x, constant(3)), op(vm.NOT, constant(9))), true}}},
+		{"callvalue", []Constraint{{op(vm.ISZERO, op(vm.LT, callvalue(), constant(100))), true}}},
+	}
+	for _, tc := range tcs {
+		m, err := p.Solve(tc.cs)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if !satisfies(m, tc.cs) {
+			t.Errorf("%s: model %v does not satisfy the constraints", tc.name, m)
+		}
+	}
+
+	cs := []Constraint{{op(vm.LT, x, constant(1)), true}, {op(vm.GT, x, constant(2)), true}}
+	if _, err := p.Solve(cs); !errors.Is(err, ErrUnsat) {
+		t.Errorf("expected %v, got %v", ErrUnsat, err)
+	}
+}
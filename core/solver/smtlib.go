@@ -0,0 +1,267 @@
+package solver
+
+import (
+	"encoding/hex"
+	"fadingrose/rosy-nigh/core/vm"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/holiman/uint256"
+)
+
+const (
+	bv256 = "(_ BitVec 256)"
+	zero  = "#x0000000000000000000000000000000000000000000000000000000000000000"
+	one   = "#x0000000000000000000000000000000000000000000000000000000000000001"
+)
+
+// smtScript is the SMT-LIB2 translation of a set of constraints
+type smtScript struct {
+	decls strings.Builder
+	defs  strings.Builder
+
+	terms     map[*vm.Expr]string
+	calldata  map[uint64]bool // declared calldata bytes
+	callvalue bool
+	fresh     int
+}
+
+// Script returns the SMT-LIB2 script asserting cs over 256-bit bitvectors
+// and asking for the value of the inputs. Calldata is declared byte by byte
+// as cd<offset>, the value as callvalue.
+func Script(cs []Constraint) string {
+	return encode(cs).String()
+}
+
+func encode(cs []Constraint) *smtScript {
+	s := &smtScript{
+		terms:    make(map[*vm.Expr]string),
+		calldata: make(map[uint64]bool),
+	}
+	roots := make([]*vm.Expr, len(cs))
+	for i, c := range cs {
+		roots[i] = c.Cond
+	}
+	vm.WalkExprs(roots, func(e *vm.Expr) {
+		s.terms[e] = s.term(e)
+	})
+	for _, c := range cs {
+		if c.Holds {
+			fmt.Fprintf(&s.defs, "(assert (not (= %s %s)))\n", s.terms[c.Cond], zero)
+		} else {
+			fmt.Fprintf(&s.defs, "(assert (= %s %s))\n", s.terms[c.Cond], zero)
+		}
+	}
+	return s
+}
+
+// String returns the whole script.
+func (s *smtScript) String() string {
+	var sb strings.Builder
+	sb.WriteString("(set-option :produce-models true)\n(set-logic QF_BV)\n")
+	sb.WriteString(s.decls.String())
+	sb.WriteString(s.defs.String())
+	sb.WriteString("(check-sat)\n")
+	if inputs := s.inputs(); len(inputs) > 0 {
+		fmt.Fprintf(&sb, "(get-value (%s))\n", strings.Join(inputs, " "))
+	}
+	return sb.String()
+}
+
+// inputs returns the names of the declared inputs, calldata by offset first.
+func (s *smtScript) inputs() []string {
+	offsets := make([]uint64, 0, len(s.calldata))
+	for offset := range s.calldata {
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+	var names []string
+	for _, offset := range offsets {
+		names = append(names, calldataName(offset))
+	}
+	if s.callvalue {
+		names = append(names, "callvalue")
+	}
+	return names
+}
+
+func calldataName(offset uint64) string {
+	return fmt.Sprintf("cd%d", offset)
+}
+
+// define binds term to a fresh name and returns the name.
+func (s *smtScript) define(term string) string {
+	name := fmt.Sprintf("e%d", s.fresh)
+	s.fresh++
+	fmt.Fprintf(&s.defs, "(define-fun %s () %s %s)\n", name, bv256, term)
+	return name
+}
+
+// term translates e, its operands are already translated. Nodes that cannot
+// be translated keep the value observed during execution.
+func (s *smtScript) term(e *vm.Expr) string {
+	switch e.Kind {
+	case vm.ExprConst:
+		return literal(&e.Value)
+	case vm.ExprVar:
+		return s.variable(e)
+	case vm.ExprOpaque:
+		return literal(&e.Value)
+	}
+
+	args := make([]string, len(e.Args))
+	for i, arg := range e.Args {
+		args[i] = s.terms[arg]
+	}
+	var term string
+	switch e.Op {
+	case vm.ADD:
+		term = fmt.Sprintf("(bvadd %s %s)", args[0], args[1])
+	case vm.MUL:
+		term = fmt.Sprintf("(bvmul %s %s)", args[0], args[1])
+	case vm.SUB:
+		term = fmt.Sprintf("(bvsub %s %s)", args[0], args[1])
+	case vm.DIV:
+		term = divide("bvudiv", args[0], args[1])
+	case vm.SDIV:
+		term = divide("bvsdiv", args[0], args[1])
+	case vm.MOD:
+		term = divide("bvurem", args[0], args[1])
+	case vm.SMOD:
+		term = divide("bvsrem", args[0], args[1])
+	case vm.ADDMOD:
+		term = modular("bvadd", args[0], args[1], args[2])
+	case vm.MULMOD:
+		term = modular("bvmul", args[0], args[1], args[2])
+	case vm.EXP:
+		exponent := e.Args[1]
+		if exponent.Kind != vm.ExprConst {
+			return literal(&e.Value)
+		}
+		return s.power(args[0], &exponent.Value)
+	case vm.SIGNEXTEND:
+		back := e.Args[0]
+		if back.Kind != vm.ExprConst {
+			return literal(&e.Value)
+		}
+		if !back.Value.LtUint64(31) {
+			return args[1]
+		}
+		bits := 8 * (back.Value.Uint64() + 1)
+		term = fmt.Sprintf("((_ sign_extend %d) ((_ extract %d 0) %s))", 256-bits, bits-1, args[1])
+	case vm.LT:
+		term = boolean(fmt.Sprintf("(bvult %s %s)", args[0], args[1]))
+	case vm.GT:
+		term = boolean(fmt.Sprintf("(bvugt %s %s)", args[0], args[1]))
+	case vm.SLT:
+		term = boolean(fmt.Sprintf("(bvslt %s %s)", args[0], args[1]))
+	case vm.SGT:
+		term = boolean(fmt.Sprintf("(bvsgt %s %s)", args[0], args[1]))
+	case vm.EQ:
+		term = boolean(fmt.Sprintf("(= %s %s)", args[0], args[1]))
+	case vm.ISZERO:
+		term = boolean(fmt.Sprintf("(= %s %s)", args[0], zero))
+	case vm.AND:
+		term = fmt.Sprintf("(bvand %s %s)", args[0], args[1])
+	case vm.OR:
+		term = fmt.Sprintf("(bvor %s %s)", args[0], args[1])
+	case vm.XOR:
+		term = fmt.Sprintf("(bvxor %s %s)", args[0], args[1])
+	case vm.NOT:
+		term = fmt.Sprintf("(bvnot %s)", args[0])
+	case vm.BYTE:
+		th := e.Args[0]
+		if th.Kind != vm.ExprConst {
+			return literal(&e.Value)
+		}
+		if !th.Value.LtUint64(32) {
+			return zero
+		}
+		hi := 255 - 8*th.Value.Uint64()
+		term = fmt.Sprintf("((_ zero_extend 248) ((_ extract %d %d) %s))", hi, hi-7, args[1])
+	// shifts of 256 bits or more behave like in the EVM
+	case vm.SHL:
+		term = fmt.Sprintf("(bvshl %s %s)", args[1], args[0])
+	case vm.SHR:
+		term = fmt.Sprintf("(bvlshr %s %s)", args[1], args[0])
+	case vm.SAR:
+		term = fmt.Sprintf("(bvashr %s %s)", args[1], args[0])
+	default:
+		return literal(&e.Value)
+	}
+	return s.define(term)
+}
+
+// variable translates an input to the variables it is declared with, any
+// other variable keeps its observed value.
+func (s *smtScript) variable(e *vm.Expr) string {
+	if isCallValue(e) {
+		if !s.callvalue {
+			s.callvalue = true
+			fmt.Fprintf(&s.decls, "(declare-const callvalue %s)\n", bv256)
+		}
+		return "callvalue"
+	}
+	offset, ok := calldataOffset(e)
+	if !ok {
+		return literal(&e.Value)
+	}
+	bytes := make([]string, 32)
+	for i := range bytes {
+		name := calldataName(offset + uint64(i))
+		if !s.calldata[offset+uint64(i)] {
+			s.calldata[offset+uint64(i)] = true
+			fmt.Fprintf(&s.decls, "(declare-const %s (_ BitVec 8))\n", name)
+		}
+		bytes[i] = name
+	}
+	return s.define(fmt.Sprintf("(concat %s)", strings.Join(bytes, " ")))
+}
+
+// power raises base to a constant exponent by square-and-multiply.
+func (s *smtScript) power(base string, exponent *uint256.Int) string {
+	var (
+		acc, square = "", base
+		bits        = exponent.ToBig()
+	)
+	for i := 0; i < bits.BitLen(); i++ {
+		if i > 0 {
+			square = s.define(fmt.Sprintf("(bvmul %s %s)", square, square))
+		}
+		switch {
+		case bits.Bit(i) == 0:
+		case acc == "":
+			acc = square
+		default:
+			acc = s.define(fmt.Sprintf("(bvmul %s %s)", acc, square))
+		}
+	}
+	if acc == "" {
+		return one
+	}
+	return acc
+}
+
+func literal(v *uint256.Int) string {
+	b := v.Bytes32()
+	return "#x" + hex.EncodeToString(b[:])
+}
+
+func boolean(cond string) string {
+	return fmt.Sprintf("(ite %s %s %s)", cond, one, zero)
+}
+
+// divide applies a division operator, division by zero gives zero in the
+// EVM while SMT-LIB defines other results.
+func divide(op, x, y string) string {
+	return fmt.Sprintf("(ite (= %s %s) %s (%s %s %s))", y, zero, zero, op, x, y)
+}
+
+// modular computes (x op y) % m without wrapping at 256 bits.
+func modular(op, x, y, m string) string {
+	wide := func(t string) string { return fmt.Sprintf("((_ zero_extend 256) %s)", t) }
+	return fmt.Sprintf("(ite (= %s %s) %s ((_ extract 255 0) (bvurem (%s %s %s) %s)))",
+		m, zero, zero, op, wide(x), wide(y), wide(m))
+}
@@ -0,0 +1,69 @@
+package solver
+
+import (
+	"fadingrose/rosy-nigh/core/vm"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+func lit(v uint64) string {
+	return literal(uint256.NewInt(v))
+}
+
+func TestScript(t *testing.T) {
+	cs := []Constraint{{op(vm.EQ, constant(0xdeadbeef), calldata(4)), true}}
+	script := Script(cs)
+
+	var bytes []string
+	for i := 4; i < 36; i++ {
+		bytes = append(bytes, fmt.Sprintf("cd%d", i))
+	}
+	for _, line := range []string{
+		"(set-logic QF_BV)",
+		"(declare-const cd4 (_ BitVec 8))",
+		"(declare-const cd35 (_ BitVec 8))",
+		fmt.Sprintf("(define-fun e0 () (_ BitVec 256) (concat %s))", strings.Join(bytes, " ")),
+		fmt.Sprintf("(define-fun e1 () (_ BitVec 256) (ite (= %s e0) %s %s))", lit(0xdeadbeef), one, zero),
+		fmt.Sprintf("(assert (not (= e1 %s)))", zero),
+		"(check-sat)",
+		fmt.Sprintf("(get-value (%s))", strings.Join(bytes, " ")),
+	} {
+		if !strings.Contains(script, line+"\n") {
+			t.Errorf("expected line %s in\n%s", line, script)
+		}
+	}
+	if strings.Contains(script, "cd36") || strings.Contains(script, "callvalue") {
+		t.Errorf("unexpected inputs in\n%s", script)
+	}
+}
+
+func TestScriptTerms(t *testing.T) {
+	v := callvalue()
+	timestamp := &vm.Expr{Kind: vm.ExprVar, Op: vm.TIMESTAMP, Value: *uint256.NewInt(1700000000)}
+	nested := &vm.Expr{Kind: vm.ExprVar, Op: vm.CALLVALUE, Value: *uint256.NewInt(5), Depth: txDepth + 1}
+	tcs := []struct {
+		name     string
+		cond     *vm.Expr
+		expected string
+	}{
+		{"sub", op(vm.SUB, v, constant(1)), fmt.Sprintf("(bvsub callvalue %s)", lit(1))},
+		{"div", op(vm.DIV, v, constant(2)), fmt.Sprintf("(ite (= %s %s) %s (bvudiv callvalue %s))", lit(2), zero, zero, lit(2))},
+		{"lt", op(vm.LT, v, constant(3)), fmt.Sprintf("(ite (bvult callvalue %s) %s %s)", lit(3), one, zero)},
+		{"shr", op(vm.SHR, constant(8), v), fmt.Sprintf("(bvlshr callvalue %s)", lit(8))},
+		{"byte", op(vm.BYTE, constant(31), v), "((_ zero_extend 248) ((_ extract 7 0) callvalue))"},
+		{"signextend", op(vm.SIGNEXTEND, constant(0), v), "((_ sign_extend 248) ((_ extract 7 0) callvalue))"},
+		{"exp", op(vm.EXP, v, constant(5)), "(bvmul callvalue e1)"},
+		{"addmod", op(vm.ADDMOD, v, v, constant(7)), fmt.Sprintf("(bvurem (bvadd ((_ zero_extend 256) callvalue) ((_ zero_extend 256) callvalue)) ((_ zero_extend 256) %s))", lit(7))},
+		{"pinned", op(vm.ADD, timestamp, v), fmt.Sprintf("(bvadd %s callvalue)", lit(1700000000))},
+		{"nested frame", op(vm.ADD, nested, v), fmt.Sprintf("(bvadd %s callvalue)", lit(5))},
+	}
+	for _, tc := range tcs {
+		script := Script([]Constraint{{tc.cond, true}})
+		if !strings.Contains(script, tc.expected) {
+			t.Errorf("%s: expected %s in\n%s", tc.name, tc.expected, script)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Package solver finds transaction inputs satisfying constraints over the
+// symbolic expressions of registers, so the fuzzer can reach the other side
+// of branches that random mutations hardly ever flip.
+package solver
+
+import (
+	"errors"
+	"fadingrose/rosy-nigh/core/vm"
+
+	"github.com/holiman/uint256"
+)
+
+var (
+	// ErrUnsat is returned when no input satisfies the constraints
+	ErrUnsat = errors.New("constraints are unsatisfiable")
+	// ErrUnknown is returned when the solver gave up, like on a timeout
+	ErrUnknown = errors.New("solver returned unknown")
+)
+
+// Constraint requires Cond to be non-zero if Holds is set, zero otherwise,
+// like the condition of a JUMPI taking or not taking the jump.
+type Constraint struct {
+	Cond  *vm.Expr
+	Holds bool
+}
+
+// Solver finds a model satisfying all constraints at once.
+type Solver interface {
+	Solve(cs []Constraint) (*Model, error)
+}
+
+// Model assigns the inputs of the transaction the constraints depend on.
+// Variables which are no input, like TIMESTAMP or the calldata of a nested
+// call, keep the value observed during execution.
+type Model struct {
+	// Calldata maps offsets to the calldata bytes read by CALLDATALOAD
+	Calldata map[uint64]byte
+	// CallValue is nil if no constraint depends on it
+	CallValue *uint256.Int
+}
+
+// Input returns a copy of input with the assigned calldata bytes, extended
+// with zeros to cover all of them.
+func (m *Model) Input(input []byte) []byte {
+	size := uint64(len(input))
+	for offset := range m.Calldata {
+		size = max(size, offset+1)
+	}
+	ret := make([]byte, size)
+	copy(ret, input)
+	for offset, b := range m.Calldata {
+		ret[offset] = b
+	}
+	return ret
+}
+
+// txDepth is the call depth of the frame running the transaction
+const txDepth = 1
+
+// maxCalldataOffset bounds the calldata a model may assign, reads beyond it
+// keep their observed value
+const maxCalldataOffset = 1 << 20
+
+// calldataOffset returns the offset of e if it loads a word of the calldata
+// of the transaction at a constant offset.
+func calldataOffset(e *vm.Expr) (uint64, bool) {
+	if e.Kind != vm.ExprVar || e.Op != vm.CALLDATALOAD || e.Depth != txDepth || len(e.Args) != 1 {
+		return 0, false
+	}
+	offset := &e.Args[0].Value
+	if e.Args[0].Kind != vm.ExprConst || !offset.IsUint64() || offset.Uint64() > maxCalldataOffset {
+		return 0, false
+	}
+	return offset.Uint64(), true
+}
+
+// isCallValue reports whether e is the value sent with the transaction.
+func isCallValue(e *vm.Expr) bool {
+	return e.Kind == vm.ExprVar && e.Op == vm.CALLVALUE && e.Depth == txDepth
+}
@@ -1,8 +1,18 @@
 package state
 
-import "github.com/ethereum/go-ethereum/common"
+import (
+	"fadingrose/rosy-nigh/core/types"
+
+	"github.com/ethereum/go-ethereum/common"
+)
 
 type Database interface {
+	// Account retrieves the account at addr, nil if it does not exist.
+	Account(addr common.Address) (*types.StateAccount, error)
+
+	// Storage retrieves the value of a storage slot of the account at addr.
+	Storage(addr common.Address, key common.Hash) (common.Hash, error)
+
 	// ContractCode retrieves a particular contract's code.
 	ContractCode(addr common.Address, codeHash common.Hash) ([]byte, error)
 
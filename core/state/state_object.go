@@ -41,6 +41,10 @@ type stateObject struct {
 	// made within the block.
 	uncommittedStorage Storage
 
+	// Flag whether the account was marked as self-destructed. The self-destructed
+	// account is still accessible in the scope of same transaction.
+	selfDestructed bool
+
 	// This is an EIP-6780 flag indicating whether the object is eligible for
 	// self-destruct according to EIP-6780. The flag could be set either when
 	// the contract is just created within the current transaction, or when the
@@ -68,6 +72,15 @@ func newObject(db *StateDB, addr common.Address, acct *types.StateAccount) *stat
 	}
 }
 
+// empty returns whether the account is considered empty (EIP-161).
+func (s *stateObject) empty() bool {
+	return s.data.Nonce == 0 && s.data.Balance.IsZero() && bytes.Equal(s.data.CodeHash, types.EmptyCodeHash.Bytes())
+}
+
+func (s *stateObject) markSelfdestructed() {
+	s.selfDestructed = true
+}
+
 // Code returns the contract code associated with this object, if any.
 func (s *stateObject) Code() []byte {
 	if len(s.code) != 0 {
@@ -118,6 +131,35 @@ func (s *stateObject) getState(key common.Hash) (common.Hash, common.Hash) {
 	return origin, origin
 }
 
+// SetState updates a value in account storage.
+func (s *stateObject) SetState(key, value common.Hash) {
+	// If the new value is the same as old, don't set. Otherwise, track only the
+	// dirty changes, supporting reverting all of it back to no change.
+	prev, origin := s.getState(key)
+	if prev == value {
+		return
+	}
+	// New value is different, update and journal the change
+	s.db.journal.append(storageChange{
+		account:   &s.address,
+		key:       key,
+		prevvalue: prev,
+		origvalue: origin,
+	})
+	s.setState(key, value, origin)
+}
+
+// setState updates a value in account dirty storage. The dirtiness will be
+// removed if the value being set equals to the original value.
+func (s *stateObject) setState(key common.Hash, value common.Hash, origin common.Hash) {
+	// Storage slot is set back to its original value, undo the dirty marker
+	if value == origin {
+		delete(s.dirtyStorage, key)
+		return
+	}
+	s.dirtyStorage[key] = value
+}
+
 // GetCommittedState retrieves the value associated with the specific key
 // without any mutations caused in the current execution.
 func (s *stateObject) GetCommittedState(key common.Hash) common.Hash {
@@ -138,10 +180,16 @@ func (s *stateObject) GetCommittedState(key common.Hash) common.Hash {
 		s.originStorage[key] = common.Hash{} // track the empty slot as origin value
 		return common.Hash{}
 	}
-	// If no live objects are available, attempt to use snapshots
-	var (
-		value common.Hash
-	)
+	// Accounts that did not exist before have no storage to load
+	if s.origin == nil || s.db.online == nil {
+		s.originStorage[key] = common.Hash{}
+		return common.Hash{}
+	}
+	value, err := s.db.online.Storage(s.address, key)
+	if err != nil {
+		s.db.setError(fmt.Errorf("can't fetch storage %x of %s online: %w", key, s.address, err))
+		return common.Hash{}
+	}
 	s.originStorage[key] = value
 	return value
 }
@@ -166,3 +214,60 @@ func (s *stateObject) CodeHash() []byte {
 func (s *stateObject) Root() common.Hash {
 	return s.data.Root
 }
+
+// SetBalance sets the balance of the account and journals the previous one.
+func (s *stateObject) SetBalance(amount *uint256.Int) {
+	s.db.journal.append(balanceChange{
+		account: &s.address,
+		prev:    new(uint256.Int).Set(s.data.Balance),
+	})
+	s.setBalance(amount)
+}
+
+// AddBalance adds amount to s's balance.
+func (s *stateObject) AddBalance(amount *uint256.Int) {
+	if amount.IsZero() {
+		return
+	}
+	s.SetBalance(new(uint256.Int).Add(s.Balance(), amount))
+}
+
+// SubBalance removes amount from s's balance.
+func (s *stateObject) SubBalance(amount *uint256.Int) {
+	if amount.IsZero() {
+		return
+	}
+	s.SetBalance(new(uint256.Int).Sub(s.Balance(), amount))
+}
+
+func (s *stateObject) setBalance(amount *uint256.Int) {
+	s.data.Balance = amount
+}
+
+// SetCode sets the code of the account and journals the previous one.
+func (s *stateObject) SetCode(codeHash common.Hash, code []byte) {
+	s.db.journal.append(codeChange{
+		account:  &s.address,
+		prevhash: s.CodeHash(),
+		prevcode: s.Code(),
+	})
+	s.setCode(codeHash, code)
+}
+
+func (s *stateObject) setCode(codeHash common.Hash, code []byte) {
+	s.code = code
+	s.data.CodeHash = codeHash[:]
+}
+
+// SetNonce sets the nonce of the account and journals the previous one.
+func (s *stateObject) SetNonce(nonce uint64) {
+	s.db.journal.append(nonceChange{
+		account: &s.address,
+		prev:    s.data.Nonce,
+	})
+	s.setNonce(nonce)
+}
+
+func (s *stateObject) setNonce(nonce uint64) {
+	s.data.Nonce = nonce
+}
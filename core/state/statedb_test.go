@@ -0,0 +1,200 @@
+package state
+
+import (
+	"bytes"
+	"fadingrose/rosy-nigh/core/tracing"
+	"fadingrose/rosy-nigh/core/types"
+	"fadingrose/rosy-nigh/core/vm"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+func TestSnapshotRevert(t *testing.T) {
+	var (
+		addr  = common.HexToAddress("0xc0de")
+		other = common.HexToAddress("0xbeef")
+		key   = common.HexToHash("0x01")
+		value = common.HexToHash("0x02")
+		code  = []byte{0x60, 0x00}
+	)
+	s := New(nil)
+	obj := s.getStateObject(addr)
+	obj.SetBalance(uint256.NewInt(1))
+
+	outer := s.Snapshot()
+	obj.SetBalance(uint256.NewInt(2))
+	obj.SetNonce(3)
+	obj.SetCode(crypto.Keccak256Hash(code), code)
+	obj.SetState(key, value)
+	s.journal.append(refundChange{prev: s.refund})
+	s.refund = 4
+	s.SetTransientState(addr, key, value)
+	s.AddSlotToAccessList(addr, key)
+
+	inner := s.Snapshot()
+	obj.SetBalance(uint256.NewInt(5))
+	s.AddAddressToAccessList(other)
+
+	s.RevertToSnapshot(inner)
+	if balance := obj.Balance(); !balance.Eq(uint256.NewInt(2)) {
+		t.Errorf("inner revert: expected balance 2, got %v", balance)
+	}
+	if s.AddressInAccessList(other) {
+		t.Errorf("inner revert: %v still in access list", other)
+	}
+	if _, slotOk := s.SlotInAccessList(addr, key); !slotOk {
+		t.Errorf("inner revert: slot dropped from access list")
+	}
+
+	s.RevertToSnapshot(outer)
+	if balance := obj.Balance(); !balance.Eq(uint256.NewInt(1)) {
+		t.Errorf("expected balance 1, got %v", balance)
+	}
+	if nonce := obj.Nonce(); nonce != 0 {
+		t.Errorf("expected nonce 0, got %d", nonce)
+	}
+	if c := obj.Code(); len(c) != 0 {
+		t.Errorf("expected no code, got %x", c)
+	}
+	if hash := obj.CodeHash(); !bytes.Equal(hash, crypto.Keccak256(nil)) {
+		t.Errorf("expected empty code hash, got %x", hash)
+	}
+	if v := obj.GetState(key); v != (common.Hash{}) {
+		t.Errorf("expected empty slot, got %v", v)
+	}
+	if s.refund != 0 {
+		t.Errorf("expected refund 0, got %d", s.refund)
+	}
+	if v := s.GetTransientState(addr, key); v != (common.Hash{}) {
+		t.Errorf("expected empty transient slot, got %v", v)
+	}
+	if addrOk, slotOk := s.SlotInAccessList(addr, key); addrOk || slotOk {
+		t.Errorf("expected empty access list, got (%v, %v)", addrOk, slotOk)
+	}
+	if s.journal.length() != 1 {
+		t.Errorf("expected 1 journal entry, got %d", s.journal.length())
+	}
+}
+
+func TestRevertInvalidSnapshot(t *testing.T) {
+	s := New(nil)
+	id := s.Snapshot()
+	s.RevertToSnapshot(id)
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic reverting to a reverted snapshot")
+		}
+	}()
+	s.RevertToSnapshot(id)
+}
+
+func TestStateDBMutations(t *testing.T) {
+	var (
+		addr = common.HexToAddress("0xc0de")
+		key  = common.HexToHash("0x01")
+	)
+	s := New(nil)
+	s.AddBalance(addr, uint256.NewInt(10), tracing.BalanceChangeTransfer)
+	s.SubBalance(addr, uint256.NewInt(3), tracing.BalanceChangeTransfer)
+	s.SetNonce(addr, 1)
+	s.SetCode(addr, []byte{0x60, 0x00})
+	s.SetState(addr, key, common.HexToHash("0x2a"))
+	s.AddRefund(5)
+
+	id := s.Snapshot()
+	s.SubRefund(2)
+	s.AddLog(&types.Log{Address: addr})
+	s.AddPreimage(key, []byte("preimage"))
+	s.SelfDestruct(addr)
+	if !s.HasSelfDestructed(addr) || !s.GetBalance(addr).IsZero() {
+		t.Errorf("expected self-destructed account with zero balance")
+	}
+	s.RevertToSnapshot(id)
+
+	if balance := s.GetBalance(addr); !balance.Eq(uint256.NewInt(7)) {
+		t.Errorf("expected balance 7, got %v", balance)
+	}
+	if s.HasSelfDestructed(addr) {
+		t.Errorf("self-destruct not reverted")
+	}
+	if nonce := s.GetNonce(addr); nonce != 1 {
+		t.Errorf("expected nonce 1, got %d", nonce)
+	}
+	if size := s.GetCodeSize(addr); size != 2 {
+		t.Errorf("expected code size 2, got %d", size)
+	}
+	if v := s.GetState(addr, key); v != common.HexToHash("0x2a") {
+		t.Errorf("expected slot 0x2a, got %v", v)
+	}
+	if refund := s.GetRefund(); refund != 5 {
+		t.Errorf("expected refund 5, got %d", refund)
+	}
+	if logs := s.GetLogs(common.Hash{}, 0); len(logs) != 0 {
+		t.Errorf("expected no logs, got %d", len(logs))
+	}
+	if len(s.Preimages()) != 0 {
+		t.Errorf("expected no preimages, got %d", len(s.Preimages()))
+	}
+}
+
+func TestStateDBRunsEVM(t *testing.T) {
+	var (
+		caller = common.HexToAddress("0xc0ffee")
+		callee = common.HexToAddress("0xc0de")
+		// stores and logs, then reverts if any calldata was given
+		code = []byte{
+			byte(vm.PUSH1), 42, byte(vm.PUSH1), 0, byte(vm.SSTORE),
+			byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.LOG0),
+			byte(vm.CALLDATASIZE), byte(vm.PUSH1), 15, byte(vm.JUMPI), byte(vm.STOP),
+			byte(vm.JUMPDEST), byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.REVERT),
+		}
+	)
+
+	s := New(nil)
+	s.SetCode(callee, code)
+	rules := params.MergedTestChainConfig.Rules(big.NewInt(1), true, 0)
+	s.Prepare(rules, caller, common.Address{}, &callee, vm.ActivePrecompiles(rules), nil)
+
+	random := common.Hash{}
+	blockCtx := vm.BlockContext{
+		CanTransfer: func(db vm.StateDB, addr common.Address, amount *uint256.Int) bool {
+			return db.GetBalance(addr).Cmp(amount) >= 0
+		},
+		Transfer: func(db vm.StateDB, sender, recipient common.Address, amount *uint256.Int) {
+			db.SubBalance(sender, amount, tracing.BalanceChangeTransfer)
+			db.AddBalance(recipient, amount, tracing.BalanceChangeTransfer)
+		},
+		BlockNumber: big.NewInt(1),
+		Random:      &random,
+	}
+	evm := vm.NewEVM(blockCtx, vm.TxContext{}, s, params.MergedTestChainConfig, vm.Config{})
+
+	// without calldata the call stores, logs and stops
+	if _, _, err := evm.Call(vm.AccountRef(caller), callee, nil, 100000, new(uint256.Int)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v := s.GetState(callee, common.Hash{}); v != common.BigToHash(big.NewInt(42)) {
+		t.Errorf("expected slot 42, got %v", v)
+	}
+	if logs := s.GetLogs(common.Hash{}, 0); len(logs) != 1 {
+		t.Errorf("expected 1 log, got %d", len(logs))
+	}
+
+	// with calldata the same writes are reverted
+	s.SetState(callee, common.Hash{}, common.Hash{})
+	if _, _, err := evm.Call(vm.AccountRef(caller), callee, []byte{1}, 100000, new(uint256.Int)); err != vm.ErrExecutionReverted {
+		t.Fatalf("expected %v, got %v", vm.ErrExecutionReverted, err)
+	}
+	if v := s.GetState(callee, common.Hash{}); v != (common.Hash{}) {
+		t.Errorf("expected reverted slot, got %v", v)
+	}
+	if logs := s.GetLogs(common.Hash{}, 0); len(logs) != 1 {
+		t.Errorf("expected reverted log, got %d logs", len(logs))
+	}
+}
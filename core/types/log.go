@@ -0,0 +1,22 @@
+package types
+
+import "github.com/ethereum/go-ethereum/common"
+
+// Log represents a contract log event. These events are generated by the LOG opcode.
+type Log struct {
+	// address of the contract that generated the event
+	Address common.Address `json:"address"`
+	// list of topics provided by the contract.
+	Topics []common.Hash `json:"topics"`
+	// supplied by the contract, usually ABI-encoded
+	Data []byte `json:"data"`
+
+	// block in which the transaction was included
+	BlockNumber uint64 `json:"blockNumber"`
+	// hash of the transaction
+	TxHash common.Hash `json:"transactionHash"`
+	// index of the transaction in the block
+	TxIndex uint `json:"transactionIndex"`
+	// index of the log in the block
+	Index uint `json:"logIndex"`
+}
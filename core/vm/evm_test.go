@@ -0,0 +1,277 @@
+package vm
+
+import (
+	"bytes"
+	"fadingrose/rosy-nigh/core/tracing"
+	"fadingrose/rosy-nigh/core/types"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// callTestState is the subset of StateDB exercised by the call tests, any
+// other method panics through the nil embedded interface.
+type callTestState struct {
+	StateDB
+	code      map[common.Address][]byte
+	balance   map[common.Address]*uint256.Int
+	nonce     map[common.Address]uint64
+	snapshots int
+	reverted  []int
+}
+
+func newCallTestState() *callTestState {
+	return &callTestState{
+		code:    make(map[common.Address][]byte),
+		balance: make(map[common.Address]*uint256.Int),
+		nonce:   make(map[common.Address]uint64),
+	}
+}
+
+func (s *callTestState) Exist(addr common.Address) bool {
+	_, ok := s.code[addr]
+	return ok || s.balance[addr] != nil
+}
+
+func (s *callTestState) Empty(addr common.Address) bool { return !s.Exist(addr) }
+
+func (s *callTestState) CreateAccount(addr common.Address) {
+	if s.balance[addr] == nil {
+		s.balance[addr] = new(uint256.Int)
+	}
+}
+
+func (s *callTestState) CreateContract(common.Address) {}
+
+func (s *callTestState) GetNonce(addr common.Address) uint64 { return s.nonce[addr] }
+
+func (s *callTestState) SetNonce(addr common.Address, nonce uint64) { s.nonce[addr] = nonce }
+
+func (s *callTestState) GetCode(addr common.Address) []byte { return s.code[addr] }
+
+func (s *callTestState) SetCode(addr common.Address, code []byte) { s.code[addr] = code }
+
+func (s *callTestState) GetStorageRoot(common.Address) common.Hash { return common.Hash{} }
+
+func (s *callTestState) GetCodeHash(addr common.Address) common.Hash {
+	return crypto.Keccak256Hash(s.code[addr])
+}
+
+func (s *callTestState) GetBalance(addr common.Address) *uint256.Int {
+	if b := s.balance[addr]; b != nil {
+		return b
+	}
+	return new(uint256.Int)
+}
+
+func (s *callTestState) AddBalance(addr common.Address, amount *uint256.Int, _ tracing.BalanceChangeReason) {
+	s.balance[addr] = new(uint256.Int).Add(s.GetBalance(addr), amount)
+}
+
+func (s *callTestState) SubBalance(addr common.Address, amount *uint256.Int, _ tracing.BalanceChangeReason) {
+	s.balance[addr] = new(uint256.Int).Sub(s.GetBalance(addr), amount)
+}
+
+func (s *callTestState) AddressInAccessList(common.Address) bool { return true }
+
+func (s *callTestState) AddAddressToAccessList(common.Address) {}
+
+func (s *callTestState) AddLog(*types.Log) {}
+
+func (s *callTestState) Snapshot() int {
+	s.snapshots++
+	return s.snapshots
+}
+
+func (s *callTestState) RevertToSnapshot(id int) { s.reverted = append(s.reverted, id) }
+
+func newCallTestEVM(statedb StateDB, config Config) *EVM {
+	random := common.Hash{}
+	blockCtx := BlockContext{
+		CanTransfer: func(db StateDB, addr common.Address, amount *uint256.Int) bool {
+			return db.GetBalance(addr).Cmp(amount) >= 0
+		},
+		Transfer: func(db StateDB, sender, recipient common.Address, amount *uint256.Int) {
+			db.SubBalance(sender, amount, tracing.BalanceChangeTransfer)
+			db.AddBalance(recipient, amount, tracing.BalanceChangeTransfer)
+		},
+		BlockNumber: big.NewInt(1),
+		Random:      &random,
+	}
+	return NewEVM(blockCtx, TxContext{}, statedb, params.MergedTestChainConfig, config)
+}
+
+// callAndReturn calls the callee with the given call opcode and returns the
+// success flag followed by the callee's 32 byte return data.
+func callAndReturn(op OpCode, callee common.Address) []byte {
+	code := []byte{
+		byte(PUSH1), 32, byte(PUSH1), 0, // retSize, retOffset
+		byte(PUSH1), 0, byte(PUSH1), 0, // inSize, inOffset
+	}
+	if op == CALL || op == CALLCODE {
+		code = append(code, byte(PUSH1), 0) // value
+	}
+	code = append(code, byte(PUSH20))
+	code = append(code, callee.Bytes()...)
+	code = append(code, byte(GAS), byte(op))
+	// MSTORE the success flag at 32 and return both words
+	code = append(code, byte(PUSH1), 32, byte(MSTORE), byte(PUSH1), 64, byte(PUSH1), 0, byte(RETURN))
+	return code
+}
+
+func TestEVMCallVariants(t *testing.T) {
+	var (
+		caller = common.HexToAddress("0xc0ffee")
+		outer  = common.HexToAddress("0x1000")
+		inner  = common.HexToAddress("0x2000")
+		logger = common.HexToAddress("0x3000")
+		// PUSH1 42; PUSH1 0; MSTORE; PUSH1 32; PUSH1 0; RETURN
+		returns42 = hexutil.MustDecode("0x602a60005260206000f3")
+		// PUSH1 0; PUSH1 0; LOG0; STOP
+		logs = hexutil.MustDecode("0x60006000a000")
+	)
+
+	tcs := []struct {
+		name    string
+		op      OpCode
+		callee  common.Address
+		success bool
+		ret     uint64
+	}{
+		{"call", CALL, inner, true, 42},
+		{"callcode", CALLCODE, inner, true, 42},
+		{"delegatecall", DELEGATECALL, inner, true, 42},
+		{"staticcall", STATICCALL, inner, true, 42},
+		{"call log", CALL, logger, true, 0},
+		{"staticcall log", STATICCALL, logger, false, 0},
+		{"call empty account", CALL, common.HexToAddress("0xdead"), true, 0},
+	}
+
+	for _, tc := range tcs {
+		statedb := newCallTestState()
+		statedb.code[outer] = callAndReturn(tc.op, tc.callee)
+		statedb.code[inner] = returns42
+		statedb.code[logger] = logs
+
+		evm := newCallTestEVM(statedb, Config{})
+		ret, _, err := evm.Call(AccountRef(caller), outer, nil, 1000000, new(uint256.Int))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if len(ret) != 64 {
+			t.Errorf("%s: expected 64 bytes, got %d", tc.name, len(ret))
+			continue
+		}
+		if success := ret[63] == 1; success != tc.success {
+			t.Errorf("%s: expected success %v, got %v", tc.name, tc.success, success)
+		}
+		if actual := new(uint256.Int).SetBytes(ret[:32]); !actual.Eq(uint256.NewInt(tc.ret)) {
+			t.Errorf("%s: expected return %d, got %v", tc.name, tc.ret, actual)
+		}
+	}
+}
+
+func TestEVMCallPrecompile(t *testing.T) {
+	statedb := newCallTestState()
+	evm := newCallTestEVM(statedb, Config{})
+
+	// 0x04 is the identity precompile
+	input := []byte("rosy-nigh")
+	ret, gas, err := evm.Call(AccountRef(common.HexToAddress("0xc0ffee")), common.BytesToAddress([]byte{4}), input, 100, new(uint256.Int))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(ret, input) {
+		t.Errorf("expected %x, got %x", input, ret)
+	}
+	if want := uint64(100 - params.IdentityBaseGas - params.IdentityPerWordGas); gas != want {
+		t.Errorf("expected %d gas left, got %d", want, gas)
+	}
+}
+
+func TestEVMCallFailure(t *testing.T) {
+	var (
+		caller = common.HexToAddress("0xc0ffee")
+		callee = common.HexToAddress("0x1000")
+	)
+	statedb := newCallTestState()
+	statedb.code[callee] = []byte{byte(INVALID)}
+	evm := newCallTestEVM(statedb, Config{})
+
+	// failing frames are reverted and consume all gas
+	_, gas, err := evm.Call(AccountRef(caller), callee, nil, 1000, new(uint256.Int))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if gas != 0 {
+		t.Errorf("expected all gas to be consumed, got %d left", gas)
+	}
+	if len(statedb.reverted) != 1 {
+		t.Errorf("expected snapshot to be reverted, got %v", statedb.reverted)
+	}
+
+	// value transfers are checked against the balance of the caller
+	_, gas, err = evm.Call(AccountRef(caller), callee, nil, 1000, uint256.NewInt(1))
+	if err != ErrInsufficientBalance {
+		t.Errorf("expected %v, got %v", ErrInsufficientBalance, err)
+	}
+	if gas != 1000 {
+		t.Errorf("expected gas to be returned, got %d", gas)
+	}
+
+	// the depth limit is enforced before anything else
+	evm.depth = int(params.CallCreateDepth) + 1
+	if _, _, err := evm.StaticCall(AccountRef(caller), callee, nil, 1000); err != ErrDepth {
+		t.Errorf("expected %v, got %v", ErrDepth, err)
+	}
+}
+
+func TestEVMCreate2(t *testing.T) {
+	var (
+		caller = common.HexToAddress("0xc0ffee")
+		salt   = uint256.NewInt(0x5a17)
+		// runtime: PUSH1 42; PUSH1 0; MSTORE; PUSH1 32; PUSH1 0; RETURN
+		runtime = hexutil.MustDecode("0x602a60005260206000f3")
+		// PUSH10 <runtime>; PUSH1 0; MSTORE; PUSH1 10; PUSH1 22; RETURN
+		initcode = append(append([]byte{byte(PUSH10)}, runtime...), hexutil.MustDecode("0x600052600a6016f3")...)
+	)
+	statedb := newCallTestState()
+	evm := newCallTestEVM(statedb, Config{})
+
+	_, addr, _, err := evm.Create2(AccountRef(caller), initcode, 1000000, new(uint256.Int), salt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := crypto.CreateAddress2(caller, salt.Bytes32(), crypto.Keccak256(initcode)); addr != want {
+		t.Errorf("expected address %v, got %v", want, addr)
+	}
+	if !bytes.Equal(statedb.code[addr], runtime) {
+		t.Errorf("expected runtime code %x, got %x", runtime, statedb.code[addr])
+	}
+	// the nonce of the caller is bumped like for CREATE
+	if nonce := statedb.nonce[caller]; nonce != 1 {
+		t.Errorf("expected caller nonce 1, got %d", nonce)
+	}
+
+	// same caller, salt and init code derive the same address again
+	_, _, gas, err := evm.Create2(AccountRef(caller), initcode, 1000000, new(uint256.Int), salt)
+	if err != ErrContractAddressCollision {
+		t.Errorf("expected %v, got %v", ErrContractAddressCollision, err)
+	}
+	if gas != 0 {
+		t.Errorf("expected all gas to be consumed, got %d left", gas)
+	}
+
+	// code starting with 0xEF is rejected since London (EIP-3541)
+	// PUSH1 0xEF; PUSH1 0; MSTORE8; PUSH1 1; PUSH1 0; RETURN
+	_, _, _, err = evm.Create2(AccountRef(caller), hexutil.MustDecode("0x60ef60005360016000f3"), 1000000, new(uint256.Int), salt)
+	if err != ErrInvalidCode {
+		t.Errorf("expected %v, got %v", ErrInvalidCode, err)
+	}
+}
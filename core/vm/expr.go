@@ -0,0 +1,283 @@
+package vm
+
+import (
+	"strings"
+
+	"github.com/holiman/uint256"
+)
+
+// ExprKind classifies the nodes of an expression DAG.
+type ExprKind uint8
+
+const (
+	// ExprConst is a value that does not depend on the input of the execution
+	ExprConst ExprKind = iota
+	// ExprVar is a free variable: a value read from the environment, like
+	// CALLVALUE, CALLDATALOAD or SLOAD, or produced by an operation the DAG
+	// does not model, like MLOAD or CALL. Its Args are the operands it was
+	// read with.
+	ExprVar
+	// ExprOp is a pure operation on its Args
+	ExprOp
+	// ExprOpaque is a value whose operands were not recorded, see
+	// RegPoolConfig. It has no Args, only its Op and observed Value are
+	// known, solvers keep it fixed to that value.
+	ExprOpaque
+)
+
+// Expr is a node of the symbolic expression of a register. Nodes are
+// hash-consed by the ExprBuilder creating them, structurally equal
+// subexpressions are the same *Expr.
+type Expr struct {
+	Kind ExprKind
+	// Op is the operation of ExprVar, ExprOp and ExprOpaque nodes
+	Op OpCode
+	// Args are the operands of Op, the top of the stack first
+	Args []*Expr
+	// Value is the constant, or the value observed during execution
+	Value uint256.Int
+	// Depth is the call depth a variable was read at
+	Depth int
+}
+
+// String returns the expression in functional notation, like
+// "EQ(0xdeadbeef, CALLDATALOAD(0x4))". Shared subexpressions are printed at
+// every use.
+func (e *Expr) String() string {
+	var sb strings.Builder
+	e.write(&sb)
+	return sb.String()
+}
+
+func (e *Expr) write(sb *strings.Builder) {
+	if e.Kind == ExprConst {
+		sb.WriteString(e.Value.Hex())
+		return
+	}
+	sb.WriteString(e.Op.String())
+	if len(e.Args) == 0 {
+		return
+	}
+	sb.WriteByte('(')
+	for i, arg := range e.Args {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		arg.write(sb)
+	}
+	sb.WriteByte(')')
+}
+
+// Vars returns the free variables e depends on, in depth-first order.
+// Variables read with a variable operand are listed after their operands.
+func (e *Expr) Vars() []*Expr {
+	var vars []*Expr
+	WalkExprs([]*Expr{e}, func(n *Expr) {
+		if n.Kind == ExprVar {
+			vars = append(vars, n)
+		}
+	})
+	return vars
+}
+
+// WalkExprs calls fn once on every node reachable from roots, operands
+// before the nodes using them.
+func WalkExprs(roots []*Expr, fn func(*Expr)) {
+	type frame struct {
+		n    *Expr
+		next int
+	}
+	var (
+		seen  = make(map[*Expr]bool)
+		stack []frame
+	)
+	for _, root := range roots {
+		if seen[root] {
+			continue
+		}
+		seen[root] = true
+		stack = append(stack, frame{n: root})
+		for len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			if top.next < len(top.n.Args) {
+				arg := top.n.Args[top.next]
+				top.next++
+				if !seen[arg] {
+					seen[arg] = true
+					stack = append(stack, frame{n: arg})
+				}
+				continue
+			}
+			fn(top.n)
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+// varScope tells which reads of a variable observe the same value.
+type varScope uint8
+
+const (
+	scopeNone       varScope = iota // not a variable
+	scopeFrame                      // fixed for a call frame
+	scopeAccount                    // state of the executing account
+	scopeGlobal                     // block and world state
+	scopeOccurrence                 // every read is a new variable
+)
+
+// exprOps classifies the operations pushing a value. Operations missing
+// from it push nothing, or push an operand again like DUP and SWAP.
+var exprOps = map[OpCode]varScope{
+	ADD: scopeNone, MUL: scopeNone, SUB: scopeNone, DIV: scopeNone, SDIV: scopeNone,
+	MOD: scopeNone, SMOD: scopeNone, ADDMOD: scopeNone, MULMOD: scopeNone, EXP: scopeNone,
+	SIGNEXTEND: scopeNone, LT: scopeNone, GT: scopeNone, SLT: scopeNone, SGT: scopeNone,
+	EQ: scopeNone, ISZERO: scopeNone, AND: scopeNone, OR: scopeNone, XOR: scopeNone,
+	NOT: scopeNone, BYTE: scopeNone, SHL: scopeNone, SHR: scopeNone, SAR: scopeNone,
+
+	ADDRESS: scopeFrame, CALLER: scopeFrame, CALLVALUE: scopeFrame,
+	CALLDATALOAD: scopeFrame, CALLDATASIZE: scopeFrame,
+
+	SLOAD: scopeAccount, TLOAD: scopeAccount, SELFBALANCE: scopeAccount,
+
+	ORIGIN: scopeGlobal, GASPRICE: scopeGlobal, COINBASE: scopeGlobal, TIMESTAMP: scopeGlobal,
+	NUMBER: scopeGlobal, DIFFICULTY: scopeGlobal, GASLIMIT: scopeGlobal, CHAINID: scopeGlobal,
+	BASEFEE: scopeGlobal, BLOBBASEFEE: scopeGlobal, BLOBHASH: scopeGlobal, BLOCKHASH: scopeGlobal,
+	BALANCE: scopeGlobal, EXTCODESIZE: scopeGlobal, EXTCODEHASH: scopeGlobal,
+
+	KECCAK256: scopeOccurrence, MLOAD: scopeOccurrence, MSIZE: scopeOccurrence,
+	GAS: scopeOccurrence, RETURNDATASIZE: scopeOccurrence, CREATE: scopeOccurrence,
+	CREATE2: scopeOccurrence, CALL: scopeOccurrence, CALLCODE: scopeOccurrence,
+	DELEGATECALL: scopeOccurrence, STATICCALL: scopeOccurrence,
+}
+
+// exprKey identifies a node for hash-consing.
+type exprKey struct {
+	kind  ExprKind
+	op    OpCode
+	args  [7]*Expr
+	scope any // *Contract, common.Address or *Reg, by varScope
+	value uint256.Int
+}
+
+// ExprBuilder turns registers into expressions. Nodes are shared across all
+// the expressions built by the same builder.
+type ExprBuilder struct {
+	nodes map[exprKey]*Expr
+	regs  map[*Reg]*Expr
+}
+
+// NewExprBuilder returns an empty builder.
+func NewExprBuilder() *ExprBuilder {
+	return &ExprBuilder{
+		nodes: make(map[exprKey]*Expr),
+		regs:  make(map[*Reg]*Expr),
+	}
+}
+
+// Build returns the expression of the value r pushed. It returns nil if the
+// operation of r pushes no value of its own, like JUMPI or DUP1. The
+// expression of a JUMPI condition is Build(r.M).
+//
+// Variables read within the same call frame with the same operands are the
+// same node. Storage and balances are read from the state as it was at the
+// time of the read, a write in between makes the next read a new variable.
+func (b *ExprBuilder) Build(r *Reg) *Expr {
+	if _, ok := exprOps[r.Op()]; !ok && !isConstOp(r.Op()) {
+		return nil
+	}
+	// operands are built before the registers using them, without recursion
+	// so that long dependency chains do not exhaust the stack
+	stack := []*Reg{r}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		if _, ok := b.regs[top]; ok {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		arity := 0
+		if !isConstOp(top.Op()) {
+			arity = min(top.operation.minStack, 7)
+		}
+		if arity > 0 && top.operand(0) == nil {
+			// operands not recorded, see RegPoolConfig
+			b.regs[top] = b.opaque(top)
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		ready := true
+		for i := 0; i < arity; i++ {
+			if _, ok := b.regs[top.operand(i)]; !ok {
+				stack = append(stack, top.operand(i))
+				ready = false
+			}
+		}
+		if !ready {
+			continue
+		}
+		args := make([]*Expr, arity)
+		for i := range args {
+			args[i] = b.regs[top.operand(i)]
+		}
+		b.regs[top] = b.node(top, args)
+		stack = stack[:len(stack)-1]
+	}
+	return b.regs[r]
+}
+
+// opaque returns a new node for r, whose operands are unknown.
+func (b *ExprBuilder) opaque(r *Reg) *Expr {
+	n := &Expr{Kind: ExprOpaque, Op: r.Op(), Value: r.Data, Depth: int(r.index[0])}
+	b.nodes[exprKey{kind: ExprOpaque, op: n.Op, scope: r, value: r.Data}] = n
+	return n
+}
+
+// isConstOp reports whether op pushes a value fixed by the code.
+func isConstOp(op OpCode) bool {
+	return op.IsPush() || op == PC || op == CODESIZE
+}
+
+// node returns the hash-consed node for r with operands args.
+func (b *ExprBuilder) node(r *Reg, args []*Expr) *Expr {
+	op := r.Op()
+	key := exprKey{op: op}
+	copy(key.args[:], args)
+
+	scope := exprOps[op]
+	switch {
+	case isConstOp(op):
+		key = exprKey{kind: ExprConst, value: r.Data}
+	case scope == scopeNone:
+		key.kind = ExprOp
+		folded := true
+		for _, arg := range args {
+			folded = folded && arg.Kind == ExprConst
+		}
+		if folded {
+			key = exprKey{kind: ExprConst, value: r.Data}
+		}
+	default:
+		key.kind = ExprVar
+		key.value = r.Data
+		switch scope {
+		case scopeFrame:
+			key.scope = r.scopeContext.Contract
+		case scopeAccount:
+			key.scope = r.scopeContext.Contract.Address()
+		case scopeOccurrence:
+			key.scope = r
+		}
+	}
+
+	if n, ok := b.nodes[key]; ok {
+		return n
+	}
+	n := &Expr{Kind: key.kind, Value: r.Data}
+	if n.Kind != ExprConst {
+		n.Op, n.Args = op, args
+	}
+	if n.Kind == ExprVar {
+		n.Depth = int(r.index[0])
+	}
+	b.nodes[key] = n
+	return n
+}
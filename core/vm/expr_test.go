@@ -0,0 +1,97 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// runRegs runs code with input and returns the registers of the execution.
+func runRegs(t *testing.T, code string, input []byte) []*Reg {
+	evm := newTestEVM(params.MergedTestChainConfig, Config{})
+	if _, err := runCode(evm, hexutil.MustDecode(code), input, 100000); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return evm.Interpreter().SymbolicPool.(*RegPool).Regs()
+}
+
+func TestExprBuild(t *testing.T) {
+	tcs := []struct {
+		name     string
+		code     string
+		expected string
+	}{
+		{"calldata", "0x600435", "CALLDATALOAD(0x4)"},
+		{"fold", "0x6002600301", "0x5"},
+		{"eq", "0x60043563deadbeef14", "EQ(0xdeadbeef, CALLDATALOAD(0x4))"},
+		{"dup", "0x6004358001", "ADD(CALLDATALOAD(0x4), CALLDATALOAD(0x4))"},
+		{"partial fold", "0x34600160020102", "MUL(0x3, CALLVALUE)"},
+		{"swap", "0x34339003", "SUB(CALLVALUE, CALLER)"},
+		{"iszero", "0x3415", "ISZERO(CALLVALUE)"},
+		{"pc", "0x5858", "0x1"},
+	}
+	for _, tc := range tcs {
+		// the last reg is the STOP running past the end of code
+		regs := runRegs(t, tc.code, nil)
+		expr := NewExprBuilder().Build(regs[len(regs)-2])
+		if expr == nil {
+			t.Errorf("%s: expected %s, got nil", tc.name, tc.expected)
+			continue
+		}
+		if expr.String() != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.expected, expr)
+		}
+	}
+}
+
+func TestExprHashConsing(t *testing.T) {
+	b := NewExprBuilder()
+
+	// PUSH1 4; CALLDATALOAD; PUSH1 4; CALLDATALOAD; ADD
+	regs := runRegs(t, "0x60043560043501", nil)
+	add := b.Build(regs[4])
+	if add.Args[0] != add.Args[1] {
+		t.Errorf("expected the two loads to be one node")
+	}
+	// both PUSH1 4 are the same constant
+	if b.Build(regs[0]) != add.Args[0].Args[0] {
+		t.Errorf("expected constants to be shared")
+	}
+
+	// GAS; GAS; ADD
+	regs = runRegs(t, "0x5a5a01", nil)
+	add = b.Build(regs[2])
+	if add.Args[0] == add.Args[1] {
+		t.Errorf("expected every GAS to be a new variable")
+	}
+	if vars := add.Vars(); len(vars) != 2 {
+		t.Errorf("expected 2 variables, got %d", len(vars))
+	}
+}
+
+func TestExprJumpiCondition(t *testing.T) {
+	// PUSH1 4; CALLDATALOAD; PUSH4 0xdeadbeef; EQ; PUSH1 0x0d; JUMPI; STOP; JUMPDEST
+	input := hexutil.MustDecode("0x00000000" + "00000000000000000000000000000000000000000000000000000000deadbeef")
+	regs := runRegs(t, "0x60043563deadbeef14600d57005b", input)
+	jumpi := regs[5]
+	if jumpi.Op() != JUMPI {
+		t.Fatalf("expected JUMPI, got %v", jumpi.Op())
+	}
+
+	b := NewExprBuilder()
+	if expr := b.Build(jumpi); expr != nil {
+		t.Errorf("expected no expression for JUMPI, got %v", expr)
+	}
+	cond := b.Build(jumpi.M)
+	if cond.Value.Uint64() != 1 {
+		t.Errorf("expected the condition to hold, got %v", cond.Value.Hex())
+	}
+	vars := cond.Vars()
+	if len(vars) != 1 || vars[0].Op != CALLDATALOAD {
+		t.Fatalf("expected CALLDATALOAD, got %v", vars)
+	}
+	if vars[0].Args[0].Value.Uint64() != 4 || vars[0].Value.Uint64() != 0xdeadbeef {
+		t.Errorf("expected CALLDATALOAD(0x4) = 0xdeadbeef, got %v = %v", vars[0], vars[0].Value.Hex())
+	}
+}
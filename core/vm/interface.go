@@ -34,6 +34,15 @@ type StateDB interface {
 	SetState(addr common.Address, key common.Hash, value common.Hash)
 	GetStorageRoot(addr common.Address) common.Hash
 
+	// support for TSTORE, TLOAD (eip 1153)
+	GetTransientState(addr common.Address, key common.Hash) common.Hash
+	SetTransientState(addr common.Address, key, value common.Hash)
+
+	// support for SELFDESTRUCT
+	SelfDestruct(common.Address)
+	HasSelfDestructed(common.Address) bool
+	Selfdestruct6780(common.Address)
+
 	// Gas Refund
 	AddRefund(uint64)
 	SubRefund(uint64)
@@ -47,10 +56,24 @@ type StateDB interface {
 	// Exist reports whether the given account exists in state.
 	// Notably this should also return true for self-destructed accounts.
 	Exist(common.Address) bool
+	// Empty returns whether the given account is empty. Empty
+	// is defined according to EIP161 (balance = nonce = code = 0).
+	Empty(common.Address) bool
+
+	// Access list (eip 2929)
+	AddressInAccessList(addr common.Address) bool
+	SlotInAccessList(addr common.Address, slot common.Hash) (addressOk bool, slotOk bool)
+	// AddAddressToAccessList adds the given address to the access list. This operation is safe to perform
+	// even if the feature/fork is not active yet
+	AddAddressToAccessList(addr common.Address)
+	// AddSlotToAccessList adds the given (address,slot) to the access list. This operation is safe to perform
+	// even if the feature/fork is not active yet
+	AddSlotToAccessList(addr common.Address, slot common.Hash)
+
+	AddLog(*types.Log)
+	AddPreimage(common.Hash, []byte)
 
-	// Snapshot
-	//
-	// WARNING: napshot not support now
+	// Snapshot and revert the journaled state changes
 	RevertToSnapshot(int)
 	Snapshot() int
 }
@@ -6,12 +6,15 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
-	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 )
 
+// SymbolicPool creates the register of every step of the interpreter.
 type SymbolicPool interface {
 	Append(pc *uint64, depth uint64, in *EVMInterpreter, ctx *ScopeContext, operation *operation) *Reg
+	// BeginTx is called by every top level call or create, even one running
+	// no code
+	BeginTx()
 }
 
 type EVMInterpreter struct {
@@ -27,7 +30,66 @@ type EVMInterpreter struct {
 	SymbolicPool
 }
 
-// TODO: Implement this
+// NewEVMInterpreter returns a new instance of the Interpreter. The jump table
+// is selected from the chain rules of the evm, with Config.ExtraEips applied on
+// top of it.
+func NewEVMInterpreter(evm *EVM) *EVMInterpreter {
+	var table *JumpTable
+	switch {
+	case evm.chainRules.IsPrague:
+		table = &pragueInstructionSet
+	case evm.chainRules.IsCancun:
+		table = &cancunInstructionSet
+	case evm.chainRules.IsShanghai:
+		table = &shanghaiInstructionSet
+	case evm.chainRules.IsMerge:
+		table = &mergeInstructionSet
+	case evm.chainRules.IsLondon:
+		table = &londonInstructionSet
+	case evm.chainRules.IsBerlin:
+		table = &berlinInstructionSet
+	case evm.chainRules.IsIstanbul:
+		table = &istanbulInstructionSet
+	case evm.chainRules.IsConstantinople:
+		table = &constantinopleInstructionSet
+	case evm.chainRules.IsByzantium:
+		table = &byzantiumInstructionSet
+	case evm.chainRules.IsEIP158:
+		table = &spuriousDragonInstructionSet
+	case evm.chainRules.IsEIP150:
+		table = &tangerineWhistleInstructionSet
+	case evm.chainRules.IsHomestead:
+		table = &homesteadInstructionSet
+	default:
+		table = &frontierInstructionSet
+	}
+	var extraEips []int
+	if len(evm.Config.ExtraEips) > 0 {
+		// Deep-copy jumptable to prevent modification of opcodes in other tables
+		table = copyJumpTable(table)
+	}
+	for _, eip := range evm.Config.ExtraEips {
+		// an eip failing to activate is dropped, so that the caller can
+		// check which ones are active in Config.ExtraEips
+		if err := EnableEIP(eip, table); err == nil {
+			extraEips = append(extraEips, eip)
+		}
+	}
+	evm.Config.ExtraEips = extraEips
+	pool := evm.Config.RegPool
+	if evm.Config.Concolic && pool.Retain == RetainNone {
+		// the path needs the registers of its branches, see RetainNone
+		pool.Retain = RetainBranches
+	}
+	return &EVMInterpreter{evm: evm, table: table, SymbolicPool: NewSymbolicPool(pool)}
+}
+
+// Run loops and evaluates the contract's code with the given input data and returns
+// the return byte-slice and an error if one occurred.
+//
+// It's important to note that any errors returned by the interpreter should be
+// considered a revert-and-consume-all-gas operation except for
+// ErrExecutionReverted which means revert-and-keep-gas-left.
 func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (ret []byte, err error) {
 	// Increment the call depth which is restricted to 1024
 	in.evm.IncreaseCallStackDepth()
@@ -124,7 +186,7 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 			return nil, &ErrStackOverflow{stackLen: sLen, limit: operation.maxStack}
 		}
 		if !contract.UseGas(cost, in.evm.Config.Tracer, tracing.GasChangeIgnored) {
-			return nil, vm.ErrOutOfGas
+			return nil, ErrOutOfGas
 		}
 
 		if operation.dynamicGas != nil {
@@ -182,15 +244,23 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 
 		in.evm.ScopeContext = callContext
 
-		// append reg and execute the operation
-		// WARNING: This may cause we save environment data repeatedly each step, which may cause performance issues
-		// in future, try to optimize this
+		// append reg and execute the operation, Config.RegPool bounds the
+		// registers kept of long executions
 		reg := in.SymbolicPool.Append(&pc, uint64(in.evm.depth), in, callContext, operation)
 		// res, err = operation.execute(&pc, in, callContext)
 		res, err = reg.execute()
 		if err != nil {
 			break
 		}
+		if op == JUMPI && in.evm.Config.Concolic {
+			in.evm.path = append(in.evm.path, Branch{
+				PC:      reg.index[1],
+				Address: contract.Address(),
+				Depth:   in.evm.depth,
+				Cond:    reg.M,
+				Taken:   !reg.M.Data.IsZero(),
+			})
+		}
 		pc++
 	}
 	if err == errStopToken {
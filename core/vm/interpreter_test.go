@@ -0,0 +1,201 @@
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+func newTestEVM(chainConfig *params.ChainConfig, config Config) *EVM {
+	random := common.Hash{}
+	blockCtx := BlockContext{
+		BlockNumber: big.NewInt(1),
+		Random:      &random,
+	}
+	return NewEVM(blockCtx, TxContext{}, nil, chainConfig, config)
+}
+
+// runCode runs code with input and gas in the frame of a contract called
+// by an account.
+func runCode(evm *EVM, code []byte, input []byte, gas uint64) ([]byte, error) {
+	caller := AccountRef(common.HexToAddress("0xc0ffee"))
+	contract := NewContract(caller, AccountRef(common.HexToAddress("0xc0de")), new(uint256.Int), gas)
+	contract.Code = code
+	return evm.Interpreter().Run(contract, input, false)
+}
+
+func TestInterpreterArithmetic(t *testing.T) {
+	tcs := []struct {
+		name     string
+		code     string
+		expected uint64
+	}{
+		{"add", "0x6002600301", 5},
+		{"sub", "0x6001600203", 1},                // 2 - 1
+		{"swap", "0x600260019003", 1},             // SWAP1 turns 1 - 2 into 2 - 1
+		{"dup", "0x600380800202", 27},             // 3 * 3 * 3
+		{"lt", "0x6002600110", 1},                 // 1 < 2
+		{"iszero", "0x600015", 1},                 // !0
+		{"shl", "0x600160041b", 16},               // 1 << 4
+		{"push0", "0x5f600101", 1},                // 0 + 1
+		{"exp", "0x600360020a", 8},                // 2 ** 3
+		{"codesize", "0x38", 9},                   // len(code)
+		{"jumpi", "0x602a600160095760ff5b", 0x2a}, // taken jump skips PUSH1 0xff
+	}
+	// PUSH1 0; MSTORE; PUSH1 32; PUSH1 0; RETURN
+	suffix := "60005260206000f3"
+
+	for _, tc := range tcs {
+		evm := newTestEVM(params.MergedTestChainConfig, Config{})
+		ret, err := runCode(evm, hexutil.MustDecode(tc.code+suffix), nil, 100000)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if actual := new(uint256.Int).SetBytes(ret); !actual.Eq(uint256.NewInt(tc.expected)) {
+			t.Errorf("%s: expected %d, got %v", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func TestInterpreterErrors(t *testing.T) {
+	tcs := []struct {
+		name string
+		code string
+		err  error
+	}{
+		{"revert", "0x60006000fd", ErrExecutionReverted},
+		{"invalid jump", "0x600356", ErrInvalidJump},
+		{"jump into push data", "0x600456605b00", ErrInvalidJump},
+		{"invalid opcode", "0xfe", &ErrInvalidOpCode{}},
+		{"stack underflow", "0x01", &ErrStackUnderflow{}},
+		{"out of gas", "0x5b600056", ErrOutOfGas},
+	}
+
+	for _, tc := range tcs {
+		evm := newTestEVM(params.MergedTestChainConfig, Config{})
+		_, err := runCode(evm, hexutil.MustDecode(tc.code), nil, 100000)
+		if err == nil || vmErrorCodeFromErr(err) != vmErrorCodeFromErr(tc.err) {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.err, err)
+		}
+	}
+}
+
+func TestInterpreterForkTables(t *testing.T) {
+	// the opcodes introduced by each fork, in fork order
+	forks := []struct {
+		name  string
+		table *JumpTable
+		ops   []OpCode
+	}{
+		{"frontier", &frontierInstructionSet, []OpCode{CREATE, CALL, CALLCODE}},
+		{"homestead", &homesteadInstructionSet, []OpCode{DELEGATECALL}},
+		{"tangerine whistle", &tangerineWhistleInstructionSet, nil},
+		{"spurious dragon", &spuriousDragonInstructionSet, nil},
+		{"byzantium", &byzantiumInstructionSet, []OpCode{STATICCALL, RETURNDATASIZE, RETURNDATACOPY, REVERT}},
+		{"constantinople", &constantinopleInstructionSet, []OpCode{SHL, SHR, SAR, EXTCODEHASH, CREATE2}},
+		{"istanbul", &istanbulInstructionSet, []OpCode{CHAINID, SELFBALANCE}},
+		{"berlin", &berlinInstructionSet, nil},
+		{"london", &londonInstructionSet, []OpCode{BASEFEE}},
+		{"merge", &mergeInstructionSet, nil},
+		{"shanghai", &shanghaiInstructionSet, []OpCode{PUSH0}},
+		{"cancun", &cancunInstructionSet, []OpCode{TLOAD, TSTORE, MCOPY, BLOBHASH, BLOBBASEFEE}},
+		{"prague", &pragueInstructionSet, nil},
+	}
+	undefined := reflect.ValueOf(opUndefined).Pointer()
+	defined := func(table *JumpTable, op OpCode) bool {
+		return reflect.ValueOf(table[op].execute).Pointer() != undefined
+	}
+	for i, fork := range forks {
+		for _, op := range fork.ops {
+			if i > 0 && defined(forks[i-1].table, op) {
+				t.Errorf("%s: %v defined before the fork", fork.name, op)
+			}
+			for _, later := range forks[i:] {
+				if !defined(later.table, op) {
+					t.Errorf("%s: %v missing", later.name, op)
+				}
+			}
+		}
+	}
+}
+
+func TestInterpreterExtraEips(t *testing.T) {
+	// PUSH0; PUSH1 0; MSTORE; PUSH1 32; PUSH1 0; RETURN
+	code := hexutil.MustDecode("0x5f60005260206000f3")
+
+	// PUSH0 is not defined before Shanghai
+	evm := newTestEVM(params.NonActivatedConfig, Config{})
+	var invalid *ErrInvalidOpCode
+	if _, err := runCode(evm, code, nil, 100000); !errors.As(err, &invalid) {
+		t.Errorf("expected invalid opcode, got %v", err)
+	}
+
+	evm = newTestEVM(params.NonActivatedConfig, Config{ExtraEips: []int{3855, 1}})
+	if eips := evm.Config.ExtraEips; len(eips) != 1 || eips[0] != 3855 {
+		t.Errorf("expected only eip 3855 to be activated, got %v", eips)
+	}
+	ret, err := runCode(evm, code, nil, 100000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(ret, make([]byte, 32)) {
+		t.Errorf("expected zero word, got %x", ret)
+	}
+
+	// activating an eip must not leak into the shared tables
+	if frontierInstructionSet[PUSH0].constantGas != 0 {
+		t.Errorf("frontier instruction set was modified")
+	}
+}
+
+func TestInterpreterConcolic(t *testing.T) {
+	// PUSH1 0; PUSH1 10; JUMPI; PUSH1 1; PUSH1 10; JUMPI; JUMPDEST
+	code := hexutil.MustDecode("0x6000600a576001600a575b")
+
+	evm := newTestEVM(params.MergedTestChainConfig, Config{})
+	if _, err := runCode(evm, code, nil, 100000); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if path := evm.Path(); path != nil {
+		t.Errorf("expected no path, got %v", path)
+	}
+
+	var (
+		caller   = AccountRef(common.HexToAddress("0xc0ffee"))
+		contract = common.HexToAddress("0xc0de")
+		statedb  = newCallTestState()
+	)
+	statedb.code[contract] = code
+	evm = newCallTestEVM(statedb, Config{Concolic: true})
+	for i := 0; i < 2; i++ {
+		if _, _, err := evm.Call(caller, contract, nil, 100000, new(uint256.Int)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		path := evm.Path()
+		if len(path) != 2 {
+			t.Fatalf("run %d: expected 2 branches, got %d", i, len(path))
+		}
+		if path[0].PC != 4 || path[0].Taken || path[1].PC != 9 || !path[1].Taken {
+			t.Errorf("run %d: unexpected path %+v", i, path)
+		}
+		if path[1].Cond.Op() != PUSH1 || path[1].Depth != 1 || path[1].Address != contract {
+			t.Errorf("run %d: unexpected branch %+v", i, path[1])
+		}
+	}
+
+	// a transaction running no code has no branches
+	if _, _, err := evm.Call(caller, common.HexToAddress("0xdead"), nil, 100000, new(uint256.Int)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if path := evm.Path(); len(path) != 0 {
+		t.Errorf("expected no branches after a call to a codeless account, got %+v", path)
+	}
+}
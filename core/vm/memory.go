@@ -1,5 +1,7 @@
 package vm
 
+import "github.com/holiman/uint256"
+
 // Memory implements a simple memory model for the ethereum virtual machine.
 type Memory struct {
 	store       []byte
@@ -11,6 +13,66 @@ func newMemory() *Memory {
 	return &Memory{}
 }
 
+// Set sets offset + size to value
+func (m *Memory) Set(offset, size uint64, value []byte) {
+	// It's possible the offset is greater than 0 and size equals 0. This is because
+	// the calcMemSize (common.go) could potentially return 0 when size is zero (NO-OP)
+	if size > 0 {
+		// length of store may never be less than offset + size.
+		// The store should be resized PRIOR to setting the memory
+		if offset+size > uint64(len(m.store)) {
+			panic("invalid memory: store empty")
+		}
+		copy(m.store[offset:offset+size], value)
+	}
+}
+
+// Set32 sets the 32 bytes starting at offset to the value of val, left-padded with zeroes to
+// 32 bytes.
+func (m *Memory) Set32(offset uint64, val *uint256.Int) {
+	// length of store may never be less than offset + size.
+	// The store should be resized PRIOR to setting the memory
+	if offset+32 > uint64(len(m.store)) {
+		panic("invalid memory: store empty")
+	}
+	// Fill in relevant bits
+	b32 := val.Bytes32()
+	copy(m.store[offset:], b32[:])
+}
+
+// GetCopy returns offset + size as a new slice
+func (m *Memory) GetCopy(offset, size uint64) (cpy []byte) {
+	if size == 0 {
+		return nil
+	}
+
+	// memory is always resized before being accessed, no need to check bounds
+	cpy = make([]byte, size)
+	copy(cpy, m.store[offset:offset+size])
+	return
+}
+
+// GetPtr returns the offset + size
+func (m *Memory) GetPtr(offset, size uint64) []byte {
+	if size == 0 {
+		return nil
+	}
+
+	// memory is always resized before being accessed, no need to check bounds
+	return m.store[offset : offset+size]
+}
+
+// Copy copies data from the src position slice into the dst position.
+// The source and destination may overlap.
+// OBS: This operation assumes that any necessary memory expansion has already been performed,
+// and this method may panic otherwise.
+func (m *Memory) Copy(dst, src, len uint64) {
+	if len == 0 {
+		return
+	}
+	copy(m.store[dst:], m.store[src:src+len])
+}
+
 // Data returns the backing slice
 func (m *Memory) Data() []byte {
 	return m.store
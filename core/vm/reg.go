@@ -35,6 +35,7 @@ func newReg(pc *uint64, index [3]uint64, interpreter *EVMInterpreter, scope *Sco
 	r.pc = pc
 	r.interpreter = interpreter
 	r.scopeContext = scope
+	r.operation = operation
 
 	init := func(param *Reg, offset int) {
 		switch offset {
@@ -57,8 +58,9 @@ func newReg(pc *uint64, index [3]uint64, interpreter *EVMInterpreter, scope *Sco
 		}
 	}
 
-	// In interpreter, we make sure items in stack at least minStack
-	for i := 0; i < operation.minStack; i++ {
+	// In interpreter, we make sure items in stack at least minStack,
+	// only the top 7 of them (enough for CALL) are recorded as parameters
+	for i := 0; i < operation.minStack && i < 7; i++ {
 		paramReg := scope.Stack.checkParam(i)
 		init(paramReg, i)
 	}
@@ -68,11 +70,31 @@ func newReg(pc *uint64, index [3]uint64, interpreter *EVMInterpreter, scope *Sco
 	return &r
 }
 
+// Op returns the opcode of the operation the reg was created for.
+func (r *Reg) Op() OpCode {
+	return r.scopeContext.Contract.GetOp(r.index[1])
+}
+
+// operand returns the i'th operand of the reg, counted from the top of the
+// stack.
+func (r *Reg) operand(i int) *Reg {
+	return [...]*Reg{r.L, r.M, r.R0, r.R1, r.R2, r.R3, r.R4}[i]
+}
+
+// forget drops the operands of the reg, it keeps its value but its
+// expression is no longer known.
+func (r *Reg) forget() {
+	r.L, r.M, r.R0, r.R1, r.R2, r.R3, r.R4 = nil, nil, nil, nil, nil, nil, nil
+}
+
 func (r *Reg) Solve() {
 	// WARNING: we handle pc* moving here instead of in the executor
-	r.operation.execute(r.pc, r.interpreter, r.scopeContext)
+	r.execute()
 }
 
+// execute runs the operation of the reg, anything the operation pushes
+// onto the stack is written into the reg itself.
 func (r *Reg) execute() ([]byte, error) {
+	r.scopeContext.Stack.reg = r
 	return r.operation.execute(r.pc, r.interpreter, r.scopeContext)
 }
@@ -1,36 +1,125 @@
 package vm
 
+// Retention selects the registers a RegPool keeps.
+type Retention uint8
+
+const (
+	// RetainAll keeps the register of every step
+	RetainAll Retention = iota
+	// RetainBranches keeps the registers of JUMPI and storage writes, the
+	// registers they depend on stay reachable through their operands
+	RetainBranches
+	// RetainRecent keeps the registers of the last RegPoolConfig.RingSize
+	// steps, older registers lose their operands so that long dependency
+	// chains are cut
+	RetainRecent
+	// RetainNone records nothing and links no operands, for pure concrete
+	// runs, see NoopPool. Concolic mode needs the registers of branches, it
+	// uses RetainBranches instead.
+	RetainNone
+)
+
+// DefaultRingSize is the RingSize used when none is set
+const DefaultRingSize = 1 << 16
+
+// RegPoolConfig bounds the memory used by the registers of an execution.
+type RegPoolConfig struct {
+	Retain   Retention
+	RingSize int // registers kept by RetainRecent
+	// ResetPerTx drops the registers of the previous transactions when a
+	// new one starts
+	ResetPerTx bool
+}
+
 type RegKey struct {
 	index [3]uint64 // depth -> pc -> loop
 	reg   *Reg
 }
 
 type RegPool struct {
+	config          RegPoolConfig
 	regkeyList      []RegKey
+	next            int                  // oldest entry of regkeyList once the ring is full
 	loopLookUpTable map[[2]uint64]uint64 // [depth,pc] -> loop
 }
 
+// NewRegPool returns a pool retaining all registers.
 func NewRegPool() *RegPool {
+	return NewRegPoolWithConfig(RegPoolConfig{})
+}
+
+// NewRegPoolWithConfig returns a pool retaining registers as set in config.
+// RetainNone is not a RegPool, use NewSymbolicPool to handle it.
+func NewRegPoolWithConfig(config RegPoolConfig) *RegPool {
+	if config.Retain == RetainRecent && config.RingSize <= 0 {
+		config.RingSize = DefaultRingSize
+	}
 	return &RegPool{
+		config:          config,
 		regkeyList:      make([]RegKey, 0),
 		loopLookUpTable: make(map[[2]uint64]uint64, 1024),
 	}
 }
 
+// NewSymbolicPool returns the pool for config, a NoopPool for RetainNone.
+func NewSymbolicPool(config RegPoolConfig) SymbolicPool {
+	if config.Retain == RetainNone {
+		return NoopPool{}
+	}
+	return NewRegPoolWithConfig(config)
+}
+
 // Append appends a new register to the register pool.
 func (rp *RegPool) Append(pc *uint64, depth uint64, in *EVMInterpreter, ctx *ScopeContext, opration *operation) *Reg {
 	loop := rp.lookup(pc, depth)
 
 	index := [3]uint64{depth, *pc, loop}
 	reg := newReg(pc, index, in, ctx, opration)
-
-	rp.regkeyList = append(rp.regkeyList, RegKey{
+	key := RegKey{
 		index: index,
 		reg:   reg,
-	})
+	}
+
+	switch rp.config.Retain {
+	case RetainBranches:
+		switch ctx.Contract.GetOp(*pc) {
+		case JUMPI, SSTORE, TSTORE:
+			rp.regkeyList = append(rp.regkeyList, key)
+		}
+	case RetainRecent:
+		if len(rp.regkeyList) < rp.config.RingSize {
+			rp.regkeyList = append(rp.regkeyList, key)
+			break
+		}
+		rp.regkeyList[rp.next].reg.forget()
+		rp.regkeyList[rp.next] = key
+		rp.next = (rp.next + 1) % len(rp.regkeyList)
+	default:
+		rp.regkeyList = append(rp.regkeyList, key)
+	}
 	return reg
 }
 
+// BeginTx drops the registers of previous transactions if the pool resets
+// per transaction.
+func (rp *RegPool) BeginTx() {
+	if !rp.config.ResetPerTx {
+		return
+	}
+	rp.regkeyList = nil
+	rp.next = 0
+	clear(rp.loopLookUpTable)
+}
+
+// Regs returns the registers retained, oldest first.
+func (rp *RegPool) Regs() []*Reg {
+	regs := make([]*Reg, 0, len(rp.regkeyList))
+	for i := range rp.regkeyList {
+		regs = append(regs, rp.regkeyList[(rp.next+i)%len(rp.regkeyList)].reg)
+	}
+	return regs
+}
+
 func (rp *RegPool) lookup(pc *uint64, depth uint64) uint64 {
 	query := [2]uint64{depth, *pc}
 	if loop, ok := rp.loopLookUpTable[query]; ok {
@@ -40,3 +129,23 @@ func (rp *RegPool) lookup(pc *uint64, depth uint64) uint64 {
 	}
 	return rp.loopLookUpTable[query]
 }
+
+// NoopPool records no registers. Its registers only carry values, they have
+// no operands, so they have no expression and concolic mode cannot use them.
+type NoopPool struct{}
+
+// Append returns a register for the step without recording it.
+func (NoopPool) Append(pc *uint64, depth uint64, in *EVMInterpreter, ctx *ScopeContext, operation *operation) *Reg {
+	r := &Reg{
+		index:        [3]uint64{depth, *pc, 0},
+		pc:           pc,
+		interpreter:  in,
+		scopeContext: ctx,
+		operation:    operation,
+	}
+	r.me = r
+	return r
+}
+
+// BeginTx implements SymbolicPool.
+func (NoopPool) BeginTx() {}
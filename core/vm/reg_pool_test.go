@@ -0,0 +1,148 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// loopCode counts down from n, the loop takes 7 steps per iteration:
+//
+//	PUSH2 n; JUMPDEST; PUSH1 1; SWAP1; SUB; DUP1; PUSH1 3; JUMPI; STOP
+func loopCode(n uint16) []byte {
+	return []byte{
+		byte(PUSH2), byte(n >> 8), byte(n), byte(JUMPDEST), byte(PUSH1), 1, byte(SWAP1), byte(SUB),
+		byte(DUP1), byte(PUSH1), 3, byte(JUMPI), byte(STOP),
+	}
+}
+
+// runPool runs code with enough gas for any loop and returns the EVM.
+func runPool(t testing.TB, config Config, code []byte) *EVM {
+	evm := newTestEVM(params.MergedTestChainConfig, config)
+	if _, err := runCode(evm, code, nil, 1<<40); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return evm
+}
+
+func TestRegPoolRetention(t *testing.T) {
+	// 1 + 7 * 10 steps, and the STOP
+	code := loopCode(10)
+	tcs := []struct {
+		name     string
+		config   RegPoolConfig
+		expected int
+	}{
+		{"all", RegPoolConfig{}, 72},
+		{"branches", RegPoolConfig{Retain: RetainBranches}, 10},
+		{"recent", RegPoolConfig{Retain: RetainRecent, RingSize: 8}, 8},
+		{"recent larger than execution", RegPoolConfig{Retain: RetainRecent, RingSize: 100}, 72},
+	}
+	for _, tc := range tcs {
+		evm := runPool(t, Config{RegPool: tc.config}, code)
+		regs := evm.Interpreter().SymbolicPool.(*RegPool).Regs()
+		if len(regs) != tc.expected {
+			t.Errorf("%s: expected %d registers, got %d", tc.name, tc.expected, len(regs))
+			continue
+		}
+		if last := regs[len(regs)-1].Op(); tc.config.Retain != RetainBranches && last != STOP {
+			t.Errorf("%s: expected STOP last, got %v", tc.name, last)
+		}
+	}
+}
+
+func TestRegPoolExpressions(t *testing.T) {
+	code := loopCode(3)
+	// the condition of the last JUMPI is n - 1 - 1 - 1
+	tcs := []struct {
+		name     string
+		config   RegPoolConfig
+		expected string
+	}{
+		{"all", RegPoolConfig{}, "0x0"},
+		{"branches", RegPoolConfig{Retain: RetainBranches}, "0x0"},
+		// the last iteration, and the STOP, are 8 steps
+		{"recent", RegPoolConfig{Retain: RetainRecent, RingSize: 8}, "SUB(SUB, 0x1)"},
+	}
+	for _, tc := range tcs {
+		evm := runPool(t, Config{Concolic: true, RegPool: tc.config}, code)
+		path := evm.Path()
+		cond := NewExprBuilder().Build(path[len(path)-1].Cond)
+		if cond.String() != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.expected, cond)
+		}
+	}
+
+	// registers of a concrete run have no operands
+	evm := runPool(t, Config{RegPool: RegPoolConfig{Retain: RetainNone}}, code)
+	if _, ok := evm.Interpreter().SymbolicPool.(NoopPool); !ok {
+		t.Fatalf("expected a NoopPool, got %T", evm.Interpreter().SymbolicPool)
+	}
+	evm = runPool(t, Config{Concolic: true, RegPool: RegPoolConfig{Retain: RetainNone}}, code)
+	if len(evm.Path()) != 3 {
+		t.Errorf("expected concolic mode to keep recording, got %d branches", len(evm.Path()))
+	}
+	if evm.Config.RegPool.Retain != RetainNone {
+		t.Errorf("expected the configuration to be left as is, got %v", evm.Config.RegPool.Retain)
+	}
+}
+
+func TestRegPoolResetPerTx(t *testing.T) {
+	var (
+		caller   = AccountRef(common.HexToAddress("0xc0ffee"))
+		contract = common.HexToAddress("0xc0de")
+	)
+	for _, reset := range []bool{false, true} {
+		statedb := newCallTestState()
+		statedb.code[contract] = loopCode(2)
+		evm := newCallTestEVM(statedb, Config{RegPool: RegPoolConfig{ResetPerTx: reset}})
+		for i := 0; i < 2; i++ {
+			if _, _, err := evm.Call(caller, contract, nil, 100000, new(uint256.Int)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		expected := 2 * 16
+		if reset {
+			expected = 16
+		}
+		pool := evm.Interpreter().SymbolicPool.(*RegPool)
+		if regs := pool.Regs(); len(regs) != expected {
+			t.Errorf("reset %v: expected %d registers, got %d", reset, expected, len(regs))
+		}
+
+		// a transaction running no code starts a new transaction too
+		if _, _, err := evm.Call(caller, common.HexToAddress("0xdead"), nil, 100000, new(uint256.Int)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if reset {
+			expected = 0
+		}
+		if regs := pool.Regs(); len(regs) != expected {
+			t.Errorf("reset %v: expected %d registers after a codeless call, got %d", reset, expected, len(regs))
+		}
+	}
+}
+
+func BenchmarkRegPool(b *testing.B) {
+	const iterations = 10000
+	code := loopCode(iterations)
+	for _, bc := range []struct {
+		name   string
+		config RegPoolConfig
+	}{
+		{"all", RegPoolConfig{}},
+		{"branches", RegPoolConfig{Retain: RetainBranches}},
+		{"recent", RegPoolConfig{Retain: RetainRecent, RingSize: 1024}},
+		{"none", RegPoolConfig{Retain: RetainNone}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				runPool(b, Config{RegPool: bc.config}, code)
+			}
+			b.ReportMetric(float64(b.N)*(7*iterations+2)/b.Elapsed().Seconds(), "steps/s")
+		})
+	}
+}
@@ -6,8 +6,16 @@ import (
 	"github.com/holiman/uint256"
 )
 
+// Stack holds the registers of a call frame. Unlike a plain value stack, an
+// item is never modified once pushed: every operation writes its result into
+// its own register (see Reg) and pushes that, so operand registers keep the
+// values they were produced with.
 type Stack struct {
 	data []*Reg
+
+	// reg is the register of the operation currently being executed, results
+	// pushed by the operation are written into it
+	reg *Reg
 }
 
 var stackPool = sync.Pool{
@@ -22,6 +30,7 @@ func newstack() *Stack {
 
 func returnStack(s *Stack) {
 	s.data = s.data[:0]
+	s.reg = nil
 	stackPool.Put(s)
 }
 
@@ -34,10 +43,53 @@ func (st *Stack) Data() []uint256.Int {
 	return data
 }
 
+// push writes d into the register of the executing operation and pushes
+// that register.
+func (st *Stack) push(d *uint256.Int) {
+	// NOTE push limit (1024) is checked in the interpreter loop
+	st.reg.Data.Set(d)
+	st.data = append(st.data, st.reg)
+}
+
+// pop removes the top register and returns a copy of its value.
+func (st *Stack) pop() (ret uint256.Int) {
+	ret = st.data[len(st.data)-1].Data
+	st.data = st.data[:len(st.data)-1]
+	return
+}
+
+// yield replaces the top of the stack with the register of the executing
+// operation, seeded with the value it replaces, and returns that value for
+// an in-place update. It is the register-safe counterpart of peeking and
+// overwriting the top item.
+func (st *Stack) yield() *uint256.Int {
+	top := st.data[len(st.data)-1]
+	st.reg.Data.Set(&top.Data)
+	st.data[len(st.data)-1] = st.reg
+	return &st.reg.Data
+}
+
 func (st *Stack) len() int {
 	return len(st.data)
 }
 
+// swap exchanges the top register with the n'th register below it.
+func (st *Stack) swap(n int) {
+	st.data[st.len()-n-1], st.data[st.len()-1] = st.data[st.len()-1], st.data[st.len()-n-1]
+}
+
+// dup pushes the n'th register again. The register itself is shared, not
+// copied, so both stack slots are backed by the same expression.
+func (st *Stack) dup(n int) {
+	st.data = append(st.data, st.data[st.len()-n])
+}
+
+// Back returns the value of the n'th item in stack. Callers must not modify
+// the returned value.
+func (st *Stack) Back(n int) *uint256.Int {
+	return &st.data[st.len()-n-1].Data
+}
+
 func (st *Stack) checkParam(offset int) *Reg {
 	if offset >= len(st.data) {
 		return nil
@@ -8,6 +8,7 @@ require (
 	github.com/holiman/uint256 v1.3.1
 	github.com/pelletier/go-toml v1.9.5
 	golang.org/x/crypto v0.22.0
+	golang.org/x/sync v0.7.0
 )
 
 require (
@@ -57,7 +58,6 @@ require (
 	github.com/tklauser/go-sysconf v0.3.12 // indirect
 	github.com/tklauser/numcpus v0.6.1 // indirect
 	golang.org/x/exp v0.0.0-20231110203233-9a3e6036ecaa // indirect
-	golang.org/x/sync v0.7.0 // indirect
 	golang.org/x/sys v0.20.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 	google.golang.org/protobuf v1.34.2 // indirect
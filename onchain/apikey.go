@@ -1,46 +1,196 @@
 package onchain
 
 import (
+	"errors"
 	"fmt"
-	"io"
 	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/pelletier/go-toml"
 )
 
 type APIKey = string
 
-// ApiKeys returns a map of API keys for each chain
-// Load keys from keys.toml
-func ApiKeys() map[Chain]APIKey {
+// KeysFileEnv names the environment variable pointing at keys.toml, it takes
+// precedence over searching the working directory and its parents.
+const KeysFileEnv = "ROSY_NIGH_KEYS"
+
+// ApiKeys returns the API keys of each chain, loaded from keys.toml. A chain
+// takes either a single key or a list of keys to rotate:
+//
+//	eth = ["KEY1", "KEY2"]
+//	bsc = "KEY"
+func ApiKeys() map[Chain][]APIKey {
 	keys, err := apikeysFromFile()
 	if err != nil {
 		fmt.Println("warning: failed to open keys.toml, online fuzzing disabled")
-		return make(map[Chain]APIKey)
+		return make(map[Chain][]APIKey)
 	}
-	var config interface{}
-	err = toml.Unmarshal(keys, &config)
+	ret, err := parseApiKeys(keys)
 	if err != nil {
 		fmt.Println("warning: failed to unmarshal keys.toml, online fuzzing disabled")
-		return make(map[Chain]APIKey)
+		return make(map[Chain][]APIKey)
+	}
+	return ret
+}
+
+func parseApiKeys(keys []byte) (map[Chain][]APIKey, error) {
+	var config map[string]interface{}
+	if err := toml.Unmarshal(keys, &config); err != nil {
+		return nil, err
 	}
-	m := config.(map[string]interface{})
-	ret := make(map[Chain]APIKey)
-	for k, v := range m {
-		if StringToChain(k) == None {
+	ret := make(map[Chain][]APIKey)
+	for k, v := range config {
+		chain := StringToChain(k)
+		if chain == None {
 			continue
 		}
-		ret[StringToChain(k)] = v.(string)
+		switch v := v.(type) {
+		case string:
+			ret[chain] = append(ret[chain], v)
+		case []interface{}:
+			for _, key := range v {
+				if key, ok := key.(string); ok {
+					ret[chain] = append(ret[chain], key)
+				}
+			}
+		default:
+			fmt.Printf("warning: invalid keys for %s in keys.toml\n", k)
+		}
 	}
-	return ret
+	return ret, nil
 }
 
 func apikeysFromFile() ([]byte, error) {
-	// recursively search for keys.toml
-	f, err := os.Open("keys.toml")
+	path := os.Getenv(KeysFileEnv)
+	if path == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		if path, err = findKeysFile(wd); err != nil {
+			return nil, err
+		}
+	}
+	keys, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open keys.toml: %w", err)
 	}
-	defer f.Close()
-	return io.ReadAll(f)
+	return keys, nil
+}
+
+// findKeysFile searches dir and its parents for keys.toml.
+func findKeysFile(dir string) (string, error) {
+	for {
+		path := filepath.Join(dir, "keys.toml")
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("keys.toml not found")
+		}
+		dir = parent
+	}
+}
+
+// tokenBucket limits the request rate of a single API key.
+type tokenBucket struct {
+	rate   float64 // tokens per second, 0 means unlimited
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes a token and returns how long to wait before using it, the
+// bucket goes negative while requests are queued.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	if b.rate <= 0 {
+		return 0
+	}
+	if !b.last.IsZero() {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// ringKey is an API key with its own rate limit.
+type ringKey struct {
+	key     APIKey
+	bucket  tokenBucket
+	revoked bool
+}
+
+// keyRing rotates the API keys of a chain round-robin. Keys rejected by the
+// explorer are revoked for the lifetime of the ring.
+type keyRing struct {
+	mu   sync.Mutex
+	keys []*ringKey
+	next int
+}
+
+// newKeyRing returns a ring over keys, each limited to rate requests per
+// second. Without keys the explorer is accessed anonymously.
+func newKeyRing(keys []APIKey, rate float64) *keyRing {
+	if len(keys) == 0 {
+		keys = []APIKey{""}
+	}
+	r := &keyRing{}
+	for _, key := range keys {
+		r.keys = append(r.keys, &ringKey{
+			key:    key,
+			bucket: tokenBucket{rate: rate, burst: max(rate, 1), tokens: max(rate, 1)},
+		})
+	}
+	return r
+}
+
+func (r *keyRing) size() int {
+	return len(r.keys)
+}
+
+// acquire picks the next usable key and waits until its rate limit allows a
+// request, it fails with ErrKeysExhausted once every key is revoked.
+func (r *keyRing) acquire() (*ringKey, error) {
+	r.mu.Lock()
+	var k *ringKey
+	for i := 0; i < len(r.keys); i++ {
+		candidate := r.keys[(r.next+i)%len(r.keys)]
+		if !candidate.revoked {
+			k = candidate
+			r.next = (r.next + i + 1) % len(r.keys)
+			break
+		}
+	}
+	if k == nil {
+		r.mu.Unlock()
+		return nil, ErrKeysExhausted
+	}
+	wait := k.bucket.reserve(time.Now())
+	r.mu.Unlock()
+
+	time.Sleep(wait)
+	return k, nil
+}
+
+// revoke stops handing out k.
+func (r *keyRing) revoke(k *ringKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k.revoked = true
+}
+
+// throttle empties the bucket of k after the explorer reported its limit
+// as reached.
+func (r *keyRing) throttle(k *ringKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k.bucket.tokens = min(k.bucket.tokens, 0)
 }
@@ -0,0 +1,221 @@
+package onchain
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestParseApiKeys(t *testing.T) {
+	keys, err := parseApiKeys([]byte(`
+eth = ["KEY1", "KEY2"]
+bsc = "KEY3"
+unknown = "KEY4"
+
+[http]
+retries = 1
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[Chain][]APIKey{ETH: {"KEY1", "KEY2"}, BSC: {"KEY3"}}
+	if len(keys) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, keys)
+	}
+	for chain, want := range expected {
+		if strings.Join(keys[chain], ",") != strings.Join(want, ",") {
+			t.Errorf("%s: expected %v, got %v", chain, want, keys[chain])
+		}
+	}
+}
+
+func TestKeysFile(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(root, "keys.toml")
+	if err := os.WriteFile(path, []byte(`eth = "KEY"`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// keys.toml is found from nested directories
+	if actual, err := findKeysFile(nested); err != nil || actual != path {
+		t.Errorf("expected %s, got %s (%v)", path, actual, err)
+	}
+
+	// the environment variable takes precedence over the search
+	other := filepath.Join(t.TempDir(), "other.toml")
+	if err := os.WriteFile(other, []byte(`eth = "OTHER"`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(KeysFileEnv, other)
+	if keys := ApiKeys(); len(keys[ETH]) != 1 || keys[ETH][0] != "OTHER" {
+		t.Errorf("expected key OTHER, got %v", keys[ETH])
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	var (
+		now = time.Unix(0, 0)
+		b   = tokenBucket{rate: 5, burst: 5, tokens: 5}
+	)
+	// the burst passes, then requests are spaced by 1/rate
+	for i := 0; i < 5; i++ {
+		if wait := b.reserve(now); wait != 0 {
+			t.Errorf("request %d: expected no wait, got %v", i, wait)
+		}
+	}
+	if wait := b.reserve(now); wait != time.Second/5 {
+		t.Errorf("expected wait %v, got %v", time.Second/5, wait)
+	}
+	if wait := b.reserve(now); wait != 2*time.Second/5 {
+		t.Errorf("expected wait %v, got %v", 2*time.Second/5, wait)
+	}
+	// tokens refill over time
+	if wait := b.reserve(now.Add(time.Second)); wait != 0 {
+		t.Errorf("expected no wait after refill, got %v", wait)
+	}
+}
+
+func TestKeyRing(t *testing.T) {
+	r := newKeyRing([]APIKey{"A", "B", "C"}, 0)
+
+	var order []APIKey
+	for i := 0; i < 4; i++ {
+		k, err := r.acquire()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		order = append(order, k.key)
+		if k.key == "B" {
+			r.revoke(k)
+		}
+	}
+	if actual := strings.Join(order, ""); actual != "ABCA" {
+		t.Errorf("expected rotation ABCA, got %s", actual)
+	}
+
+	for _, k := range r.keys {
+		r.revoke(k)
+	}
+	if _, err := r.acquire(); !errors.Is(err, ErrKeysExhausted) {
+		t.Errorf("expected %v, got %v", ErrKeysExhausted, err)
+	}
+}
+
+func TestExplorerKeyRotation(t *testing.T) {
+	tcs := []struct {
+		responses map[APIKey]string
+		err       error
+		limited   bool
+		used      string
+	}{
+		{
+			// a rejected key is skipped for the next one
+			responses: map[APIKey]string{
+				"A": `{"status":"0","message":"NOTOK","result":"Invalid API Key"}`,
+				"B": `{"jsonrpc":"2.0","id":1,"result":"0x6001"}`,
+			},
+			used: "AB",
+		},
+		{
+			responses: map[APIKey]string{
+				"A": `{"status":"0","message":"NOTOK","result":"Invalid API Key"}`,
+				"B": `{"status":"0","message":"NOTOK","result":"Invalid API Key"}`,
+			},
+			err:  ErrKeysExhausted,
+			used: "AB",
+		},
+		{
+			// rate limited keys are retried, they are not exhausted
+			responses: map[APIKey]string{
+				"A": `{"status":"0","message":"NOTOK","result":"Max rate limit reached"}`,
+				"B": `{"status":"0","message":"NOTOK","result":"Max calls per sec rate limit reached (5/sec)"}`,
+			},
+			limited: true,
+			used:    "ABABAB",
+		},
+	}
+
+	for _, tc := range tcs {
+		var (
+			mu   sync.Mutex
+			used string
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.URL.Query().Get("apikey")
+			mu.Lock()
+			used += key
+			mu.Unlock()
+			w.Write([]byte(tc.responses[key]))
+		}))
+
+		db := NewOnChainDataBase(ETH)
+		db.apikeys = []APIKey{"A", "B"}
+		if err := db.SetTransport(TransportConfig{Timeout: time.Second, Transport: redirect{server}}); err != nil {
+			t.Fatal(err)
+		}
+		_, err := db.ContractCode(common.HexToAddress("0xc0de"), common.Hash{})
+		var explorerErr *ExplorerError
+		if tc.limited {
+			if !errors.As(err, &explorerErr) || !explorerErr.rateLimited() || errors.Is(err, ErrKeysExhausted) {
+				t.Errorf("expected a rate limit error, got %v", err)
+			}
+		} else if !errors.Is(err, tc.err) {
+			t.Errorf("expected %v, got %v", tc.err, err)
+		}
+		if used != tc.used {
+			t.Errorf("expected keys %s, got %s", tc.used, used)
+		}
+		server.Close()
+	}
+}
+
+func TestExplorerRateLimitRetry(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []time.Time
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, time.Now())
+		first := len(requests) == 1
+		mu.Unlock()
+		if first {
+			w.Write([]byte(`{"status":"0","message":"NOTOK","result":"Max calls per sec rate limit reached (5/sec)"}`))
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x6001"}`))
+	}))
+	defer server.Close()
+
+	// a single key is retried once its bucket refills
+	db := NewOnChainDataBase(ETH)
+	db.apikeys = []APIKey{"A"}
+	if err := db.SetTransport(TransportConfig{Timeout: time.Second, RateLimit: 5, Transport: redirect{server}}); err != nil {
+		t.Fatal(err)
+	}
+	code, err := db.ContractCode(common.HexToAddress("0xc0de"), common.Hash{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(code) != 2 || code[0] != 0x60 {
+		t.Errorf("expected code 0x6001, got %x", code)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	if wait := requests[1].Sub(requests[0]); wait < time.Second/5/2 {
+		t.Errorf("expected the retry to wait for the bucket, waited %v", wait)
+	}
+}
@@ -0,0 +1,138 @@
+package onchain
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrOffline is returned in offline mode when the requested data is not in
+// the disk cache.
+var ErrOffline = errors.New("offline mode: not in cache")
+
+// DiskCache persists onchain data across runs, one file per item under
+// <dir>/<chain>/<block>/<address>/<kind>.
+//
+// Items fetched at the "latest" tag are cached as well, pin a block number to
+// keep campaigns reproducible.
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache returns a disk cache rooted at dir, creating it if needed.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+type cacheKind string
+
+const (
+	cacheCode    cacheKind = "code"
+	cacheBalance cacheKind = "balance"
+	cacheNonce   cacheKind = "nonce"
+	cacheStorage cacheKind = "storage"
+	cacheSource  cacheKind = "source"
+	cacheABI     cacheKind = "abi"
+)
+
+type cacheKey struct {
+	chain   Chain
+	block   string // empty for data that does not depend on the block
+	address common.Address
+	kind    cacheKind
+	slot    common.Hash // only for cacheStorage
+}
+
+func (k cacheKey) String() string {
+	at := k.chain.String()
+	if k.block != "" {
+		at += "@" + k.block
+	}
+	if k.kind == cacheStorage {
+		return fmt.Sprintf("%s %s %s %s", at, k.address, k.kind, k.slot)
+	}
+	return fmt.Sprintf("%s %s %s", at, k.address, k.kind)
+}
+
+func (c *DiskCache) path(k cacheKey) string {
+	name := string(k.kind)
+	if k.kind == cacheStorage {
+		name += "-" + k.slot.Hex()
+	}
+	return filepath.Join(c.dir, k.chain.String(), k.block, k.address.Hex(), name)
+}
+
+func (c *DiskCache) get(k cacheKey) ([]byte, bool) {
+	data, err := os.ReadFile(c.path(k))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func (c *DiskCache) put(k cacheKey, data []byte) error {
+	path := c.path(k)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// write to a temporary file first so readers never see partial items
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// cached serves k from memory or the disk cache, or fetches and stores it.
+// Concurrent misses of the same key share a single fetch. In offline mode
+// misses fail with ErrOffline instead of fetching.
+func (c *OnChainDataBase) cached(k cacheKey, fetch func() ([]byte, error)) ([]byte, error) {
+	if data, ok := c.mem.Get(k); ok {
+		return data, nil
+	}
+	v, err, _ := c.inflight.Do(k.String(), func() (interface{}, error) {
+		// a flight for k may have landed since the check above
+		if data, ok := c.mem.Get(k); ok {
+			return data, nil
+		}
+		if c.Cache != nil {
+			if data, ok := c.Cache.get(k); ok {
+				c.mem.Add(k, data)
+				return data, nil
+			}
+		}
+		if c.Offline {
+			return nil, fmt.Errorf("%w: %s", ErrOffline, k)
+		}
+		data, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		c.mem.Add(k, data)
+		if c.Cache != nil {
+			if err := c.Cache.put(k, data); err != nil {
+				fmt.Printf("warning: failed to cache %s: %v\n", k, err)
+			}
+		}
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
@@ -0,0 +1,82 @@
+package onchain
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+)
+
+func TestDiskCache(t *testing.T) {
+	var (
+		addr  = common.HexToAddress("0xf75e354c5edc8efed9b59ee9f67a80845ade7d0c")
+		slot  = common.HexToHash("0x01")
+		calls []rpcRequest
+	)
+	node := newTestNode(t, map[string]string{
+		"eth_getBalance":          "0x64",
+		"eth_getTransactionCount": "0x1",
+		"eth_getCode":             "0x6001600055",
+		"eth_getStorageAt":        "0x000000000000000000000000000000000000000000000000000000000000002a",
+	}, &calls)
+	defer node.Close()
+
+	cache, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := big.NewInt(100)
+
+	// the first run fetches from the node and fills the cache
+	online := NewForkDataBase(ETH, node.URL, block)
+	online.Cache = cache
+	if _, err := online.Account(addr); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := online.Storage(addr, slot); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(calls) != 4 {
+		t.Fatalf("expected 4 calls, got %d", len(calls))
+	}
+
+	// an offline run is served from disk only
+	offline := NewForkDataBase(ETH, node.URL, block)
+	offline.Cache = cache
+	offline.Offline = true
+	acct, err := offline.Account(addr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !acct.Balance.Eq(uint256.NewInt(100)) || acct.Nonce != 1 {
+		t.Errorf("unexpected account %+v", acct)
+	}
+	code, err := offline.ContractCode(addr, common.BytesToHash(acct.CodeHash))
+	if err != nil || !bytes.Equal(code, common.FromHex("0x6001600055")) {
+		t.Errorf("unexpected code %x, err %v", code, err)
+	}
+	if value, err := offline.Storage(addr, slot); err != nil || value != common.BigToHash(big.NewInt(42)) {
+		t.Errorf("unexpected slot %v, err %v", value, err)
+	}
+	if len(calls) != 4 {
+		t.Errorf("offline run hit the network, %d calls", len(calls))
+	}
+
+	// misses fail clearly instead of going online
+	if _, err := offline.Storage(addr, common.HexToHash("0x02")); !errors.Is(err, ErrOffline) {
+		t.Errorf("expected %v, got %v", ErrOffline, err)
+	}
+	// other blocks are cached separately
+	other := NewForkDataBase(ETH, node.URL, big.NewInt(101))
+	other.Cache = cache
+	other.Offline = true
+	if _, err := other.Account(addr); !errors.Is(err, ErrOffline) {
+		t.Errorf("expected %v, got %v", ErrOffline, err)
+	}
+	if len(calls) != 4 {
+		t.Errorf("offline run hit the network, %d calls", len(calls))
+	}
+}
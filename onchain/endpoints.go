@@ -1,26 +1,107 @@
 package onchain
 
-import "strings"
+import (
+	"fmt"
+	"strings"
 
-func (c Chain) endpoint(method remoteCall, args map[string]string) string {
-	return c.endpointBase() + remoteCalls()[c][method].impl(args)
+	"github.com/pelletier/go-toml"
+)
+
+// Endpoints are the explorer API and JSON-RPC node of a chain. Explorers are
+// expected to speak the Etherscan API.
+type Endpoints struct {
+	Explorer string `toml:"explorer"`
+	RPC      string `toml:"rpc"`
+}
+
+// defaultEndpoints lists public endpoints of the supported chains, an empty
+// field means the chain has no such endpoint by default.
+var defaultEndpoints = map[Chain]Endpoints{
+	ETH:          {Explorer: "https://api.etherscan.io/api", RPC: "https://eth.llamarpc.com"},
+	GOERLI:       {Explorer: "https://api-goerli.etherscan.io/api", RPC: "https://rpc.ankr.com/eth_goerli"},
+	SEPOLIA:      {Explorer: "https://api-sepolia.etherscan.io/api", RPC: "https://rpc.sepolia.org"},
+	BSC:          {Explorer: "https://api.bscscan.com/api", RPC: "https://bsc-dataseed.bnbchain.org"},
+	CHAPEL:       {Explorer: "https://api-testnet.bscscan.com/api", RPC: "https://data-seed-prebsc-1-s1.bnbchain.org:8545"},
+	POLYGON:      {Explorer: "https://api.polygonscan.com/api", RPC: "https://polygon-rpc.com"},
+	MUMBAI:       {Explorer: "https://api-testnet.polygonscan.com/api", RPC: "https://rpc-mumbai.maticvigil.com"},
+	FANTOM:       {Explorer: "https://api.ftmscan.com/api", RPC: "https://rpc.ftm.tools"},
+	AVALANCHE:    {Explorer: "https://api.snowtrace.io/api", RPC: "https://api.avax.network/ext/bc/C/rpc"},
+	OPTIMISM:     {Explorer: "https://api-optimistic.etherscan.io/api", RPC: "https://mainnet.optimism.io"},
+	ARBITRUM:     {Explorer: "https://api.arbiscan.io/api", RPC: "https://arb1.arbitrum.io/rpc"},
+	GNOSIS:       {Explorer: "https://api.gnosisscan.io/api", RPC: "https://rpc.gnosischain.com"},
+	BASE:         {Explorer: "https://api.basescan.org/api", RPC: "https://mainnet.base.org"},
+	CELO:         {Explorer: "https://api.celoscan.io/api", RPC: "https://forno.celo.org"},
+	ZKEVM:        {Explorer: "https://api-zkevm.polygonscan.com/api", RPC: "https://zkevm-rpc.com"},
+	ZkevmTestnet: {Explorer: "https://api-testnet-zkevm.polygonscan.com/api", RPC: "https://rpc.public.zkevm-test.net"},
+	BLAST:        {Explorer: "https://api.blastscan.io/api", RPC: "https://rpc.blast.io"},
+	LINEA:        {Explorer: "https://api.lineascan.build/api", RPC: "https://rpc.linea.build"},
+	LOCAL:        {RPC: "http://localhost:8545"},
+	IOTEX:        {RPC: "https://babel-api.mainnet.iotex.io"},
+	SCROLL:       {Explorer: "https://api.scrollscan.com/api", RPC: "https://rpc.scroll.io"},
 }
 
-func (c Chain) endpointBase() string {
-	switch c {
-	case ETH:
-		return "https://api.etherscan.io/api"
-	default:
-		return ""
+// LoadEndpoints returns the default endpoints overridden by the
+// [endpoints.<chain>] tables of keys.toml:
+//
+//	[endpoints.local]
+//	rpc = "http://127.0.0.1:8545"
+//	explorer = "http://127.0.0.1:4000/api"
+func LoadEndpoints() map[Chain]Endpoints {
+	keys, err := apikeysFromFile()
+	if err != nil {
+		return parseEndpoints(nil)
 	}
+	return parseEndpoints(keys)
+}
+
+func parseEndpoints(keys []byte) map[Chain]Endpoints {
+	ret := make(map[Chain]Endpoints, len(defaultEndpoints))
+	for chain, e := range defaultEndpoints {
+		ret[chain] = e
+	}
+	var config struct {
+		Endpoints map[string]Endpoints `toml:"endpoints"`
+	}
+	if err := toml.Unmarshal(keys, &config); err != nil {
+		fmt.Println("warning: failed to unmarshal keys.toml, using default endpoints")
+		return ret
+	}
+	for name, override := range config.Endpoints {
+		chain := StringToChain(name)
+		if chain == None {
+			fmt.Printf("warning: unknown chain %q in keys.toml endpoints\n", name)
+			continue
+		}
+		e := ret[chain]
+		if override.Explorer != "" {
+			e.Explorer = override.Explorer
+		}
+		if override.RPC != "" {
+			e.RPC = override.RPC
+		}
+		ret[chain] = e
+	}
+	return ret
+}
+
+func (e Endpoints) explorer(method remoteCall, args map[string]string) string {
+	return e.Explorer + remoteCalls()[method].impl(args)
 }
 
 type remoteCall int
 
 const (
-	callcode = iota
+	callcode remoteCall = iota
+	getsourcecode
+	getabi
+	getstorageat
+	ethcall
 )
 
+func (r remoteCall) String() string {
+	return [...]string{"eth_getCode", "getsourcecode", "getabi", "eth_getStorageAt", "eth_call"}[r]
+}
+
 type remoteCallTemplate string
 
 func (rct remoteCallTemplate) impl(args map[string]string) string {
@@ -31,10 +112,13 @@ func (rct remoteCallTemplate) impl(args map[string]string) string {
 	return ret
 }
 
-func remoteCalls() map[Chain]map[remoteCall]remoteCallTemplate {
-	return map[Chain]map[remoteCall]remoteCallTemplate{
-		ETH: {
-			callcode: "?module=proxy&action=eth_getCode&address=<ADDRESS>&tag=latest&apikey=<API_KEY>",
-		},
+// remoteCalls are shared by every Etherscan compatible explorer.
+func remoteCalls() map[remoteCall]remoteCallTemplate {
+	return map[remoteCall]remoteCallTemplate{
+		callcode:      "?module=proxy&action=eth_getCode&address=<ADDRESS>&tag=latest&apikey=<API_KEY>",
+		getsourcecode: "?module=contract&action=getsourcecode&address=<ADDRESS>&apikey=<API_KEY>",
+		getabi:        "?module=contract&action=getabi&address=<ADDRESS>&apikey=<API_KEY>",
+		getstorageat:  "?module=proxy&action=eth_getStorageAt&address=<ADDRESS>&position=<POSITION>&tag=latest&apikey=<API_KEY>",
+		ethcall:       "?module=proxy&action=eth_call&to=<ADDRESS>&data=<DATA>&tag=latest&apikey=<API_KEY>",
 	}
 }
@@ -21,9 +21,48 @@ func TestEndpoints(t *testing.T) {
 		},
 	}
 	for _, tc := range tcs {
-		actual := tc.endpoint(tc.remoteCall, tc.args)
+		actual := defaultEndpoints[tc.Chain].explorer(tc.remoteCall, tc.args)
 		if actual != tc.expected {
 			t.Errorf("expected %s got %s", tc.expected, actual)
 		}
 	}
 }
+
+func TestEndpointRegistry(t *testing.T) {
+	// every declared chain has at least a node to fork from
+	for chain := Chain(ETH); chain <= SCROLL; chain++ {
+		if defaultEndpoints[chain].RPC == "" {
+			t.Errorf("%s: missing rpc endpoint", chain)
+		}
+		if StringToChain(chain.String()) != chain {
+			t.Errorf("%s: name does not round trip", chain)
+		}
+	}
+
+	endpoints := parseEndpoints([]byte(`
+eth = "KEY"
+
+[endpoints.local]
+rpc = "http://127.0.0.1:9545"
+explorer = "http://127.0.0.1:4000/api"
+
+[endpoints.bsc]
+rpc = "https://bsc.example"
+
+[endpoints.unknown]
+rpc = "https://unknown.example"
+`))
+	tcs := []struct {
+		chain    Chain
+		expected Endpoints
+	}{
+		{chain: LOCAL, expected: Endpoints{Explorer: "http://127.0.0.1:4000/api", RPC: "http://127.0.0.1:9545"}},
+		{chain: BSC, expected: Endpoints{Explorer: "https://api.bscscan.com/api", RPC: "https://bsc.example"}},
+		{chain: ETH, expected: defaultEndpoints[ETH]},
+	}
+	for _, tc := range tcs {
+		if actual := endpoints[tc.chain]; actual != tc.expected {
+			t.Errorf("%s: expected %+v, got %+v", tc.chain, tc.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package onchain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrCodeHashMismatch is returned when fetched code does not hash to the
+// code hash it was requested for.
+var ErrCodeHashMismatch = errors.New("code hash mismatch")
+
+// ErrKeysExhausted is returned when every API key of a chain has been
+// rejected by the explorer.
+var ErrKeysExhausted = errors.New("all explorer API keys exhausted")
+
+// RPCError is a JSON-RPC error object, returned by nodes and by the proxy
+// module of explorers.
+type RPCError struct {
+	Method  string
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s: rpc error %d: %s", e.Method, e.Code, e.Message)
+}
+
+// ExplorerError is an explorer response with status "0", e.g. an invalid API
+// key or an exceeded rate limit.
+type ExplorerError struct {
+	Message string
+	Result  string
+}
+
+func (e *ExplorerError) Error() string {
+	if e.Result == "" {
+		return fmt.Sprintf("explorer error: %s", e.Message)
+	}
+	return fmt.Sprintf("explorer error: %s: %s", e.Message, e.Result)
+}
+
+// invalidKey reports whether the explorer rejected the API key.
+func (e *ExplorerError) invalidKey() bool {
+	return strings.Contains(strings.ToLower(e.Result), "invalid api key")
+}
+
+// rateLimited reports whether the explorer refused the request because of
+// its rate limit.
+func (e *ExplorerError) rateLimited() bool {
+	return strings.Contains(strings.ToLower(e.Result), "rate limit")
+}
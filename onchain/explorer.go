@@ -0,0 +1,96 @@
+package onchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// explorerResponse covers both shapes returned by Etherscan compatible
+// explorers: JSON-RPC envelopes from the proxy module and status envelopes
+// from every other module.
+type explorerResponse struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Result  json.RawMessage `json:"result"`
+	Error   *RPCError       `json:"error"`
+}
+
+// explorerRetries bounds the requests of a call refused by the rate limit of
+// the explorer, per API key.
+const explorerRetries = 3
+
+// explorerCall invokes method on the explorer of the chain and decodes the
+// result into result. API keys are rotated on every request, a key rejected
+// by the explorer is revoked and a rate limited one throttled, the request is
+// then retried with the next key, or with the same key once its bucket
+// refilled. It fails with ErrKeysExhausted once every key is revoked.
+func (c *OnChainDataBase) explorerCall(result interface{}, method remoteCall, args map[string]string) error {
+	if c.endpoints.Explorer == "" {
+		return fmt.Errorf("no explorer endpoint configured for %s", c.chain)
+	}
+	if args == nil {
+		args = make(map[string]string)
+	}
+
+	var lastErr error
+	for limited := 0; limited < explorerRetries*c.keys.size(); {
+		key, err := c.keys.acquire()
+		if err != nil {
+			if lastErr == nil {
+				return err
+			}
+			return fmt.Errorf("%w: %w", err, lastErr)
+		}
+		args["API_KEY"] = key.key
+		err = c.explorerRequest(result, method, args)
+
+		var explorerErr *ExplorerError
+		if !errors.As(err, &explorerErr) {
+			return err
+		}
+		switch {
+		case explorerErr.invalidKey():
+			c.keys.revoke(key)
+		case explorerErr.rateLimited():
+			c.keys.throttle(key)
+			limited++
+		default:
+			return err
+		}
+		lastErr = err
+	}
+	return lastErr
+}
+
+// explorerRequest sends a single request with the given arguments.
+func (c *OnChainDataBase) explorerRequest(result interface{}, method remoteCall, args map[string]string) error {
+	resp, err := c.http.get(c.endpoints.explorer(method, args))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("explorer: unexpected status %s", resp.Status)
+	}
+
+	var r explorerResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("explorer: %w", err)
+	}
+	if r.Error != nil {
+		r.Error.Method = method.String()
+		return r.Error
+	}
+	if r.Status == "0" {
+		// the reason of a failure is usually a string in result
+		var reason string
+		json.Unmarshal(r.Result, &reason)
+		return &ExplorerError{Message: r.Message, Result: reason}
+	}
+	if err := json.Unmarshal(r.Result, result); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	return nil
+}
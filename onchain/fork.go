@@ -0,0 +1,113 @@
+package onchain
+
+import (
+	"encoding/binary"
+	"errors"
+	"fadingrose/rosy-nigh/core/types"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/holiman/uint256"
+)
+
+var errNoRPC = errors.New("no JSON-RPC endpoint configured")
+
+// NewForkDataBase returns a database that lazily pulls accounts, code and
+// storage of chain from the JSON-RPC node at endpoint, pinned to the given
+// block. An empty endpoint uses the configured node of chain, a nil block
+// follows the latest block of the node.
+func NewForkDataBase(chain Chain, endpoint string, block *big.Int) *OnChainDataBase {
+	db := NewOnChainDataBase(chain)
+	if endpoint == "" {
+		endpoint = db.endpoints.RPC
+	}
+	if endpoint != "" {
+		db.rpc = newRPCClient(endpoint, db.http)
+	}
+	if block != nil {
+		db.block = hexutil.EncodeBig(block)
+	}
+	return db
+}
+
+// Account fetches balance, nonce and code of addr, it returns nil if the
+// account is empty.
+func (c *OnChainDataBase) Account(address common.Address) (*types.StateAccount, error) {
+	if c.rpc == nil {
+		return nil, errNoRPC
+	}
+	balance, err := c.cached(c.key(address, cacheBalance), func() ([]byte, error) {
+		var balance hexutil.Big
+		if err := c.rpc.call(&balance, "eth_getBalance", address, c.block); err != nil {
+			return nil, err
+		}
+		return balance.ToInt().Bytes(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := c.cached(c.key(address, cacheNonce), func() ([]byte, error) {
+		var nonce hexutil.Uint64
+		if err := c.rpc.call(&nonce, "eth_getTransactionCount", address, c.block); err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.AppendUint64(nil, uint64(nonce)), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	code, err := c.forkCode(address)
+	if err != nil {
+		return nil, err
+	}
+
+	acct := types.NewEmptyStateAccount()
+	acct.Balance = new(uint256.Int).SetBytes(balance)
+	if len(nonce) == 8 {
+		acct.Nonce = binary.BigEndian.Uint64(nonce)
+	}
+	if acct.Balance.IsZero() && acct.Nonce == 0 && len(code) == 0 {
+		return nil, nil
+	}
+	if len(code) > 0 {
+		hash := hasher(code)
+		c.CodeCache.Add(hash, code)
+		acct.CodeHash = hash.Bytes()
+	}
+	return acct, nil
+}
+
+// Storage fetches the value of the storage slot key of addr.
+func (c *OnChainDataBase) Storage(address common.Address, key common.Hash) (common.Hash, error) {
+	if c.rpc == nil {
+		return common.Hash{}, errNoRPC
+	}
+	k := c.key(address, cacheStorage)
+	k.slot = key
+	value, err := c.cached(k, func() ([]byte, error) {
+		var value hexutil.Bytes
+		if err := c.rpc.call(&value, "eth_getStorageAt", address, key, c.block); err != nil {
+			return nil, err
+		}
+		return value, nil
+	})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(value), nil
+}
+
+func (c *OnChainDataBase) forkCode(address common.Address) ([]byte, error) {
+	return c.cached(c.key(address, cacheCode), func() ([]byte, error) {
+		var code hexutil.Bytes
+		if err := c.rpc.call(&code, "eth_getCode", address, c.block); err != nil {
+			return nil, err
+		}
+		return code, nil
+	})
+}
+
+func (c *OnChainDataBase) key(address common.Address, kind cacheKind) cacheKey {
+	return cacheKey{chain: c.chain, block: c.block, address: address, kind: kind}
+}
@@ -0,0 +1,182 @@
+package onchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fadingrose/rosy-nigh/core/state"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+)
+
+// newTestNode starts a stand-in JSON-RPC node answering each method with
+// results[method], every request is recorded in calls.
+func newTestNode(t *testing.T, results map[string]string, calls *[]rpcRequest) *httptest.Server {
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request: %v", err)
+			return
+		}
+		mu.Lock()
+		*calls = append(*calls, req)
+		mu.Unlock()
+		result, ok := results[req.Method]
+		if !ok {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"jsonrpc": "2.0", "id": req.Id,
+				"error": map[string]interface{}{"code": -32601, "message": "method not found"},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"jsonrpc": "2.0", "id": req.Id, "result": result,
+		})
+	}))
+}
+
+func TestForkDataBase(t *testing.T) {
+	var (
+		addr  = common.HexToAddress("0xf75e354c5edc8efed9b59ee9f67a80845ade7d0c")
+		code  = common.FromHex("0x6001600055")
+		calls []rpcRequest
+	)
+	node := newTestNode(t, map[string]string{
+		"eth_getBalance":          "0xde0b6b3a7640000",
+		"eth_getTransactionCount": "0x2",
+		"eth_getCode":             "0x6001600055",
+		"eth_getStorageAt":        "0x000000000000000000000000000000000000000000000000000000000000002a",
+	}, &calls)
+	defer node.Close()
+
+	db := NewForkDataBase(ETH, node.URL, big.NewInt(20000000))
+	statedb := state.New(db)
+
+	if balance := statedb.GetBalance(addr); !balance.Eq(uint256.NewInt(1e18)) {
+		t.Errorf("expected balance 1e18, got %v", balance)
+	}
+	if nonce := statedb.GetNonce(addr); nonce != 2 {
+		t.Errorf("expected nonce 2, got %d", nonce)
+	}
+	if actual := statedb.GetCode(addr); !bytes.Equal(actual, code) {
+		t.Errorf("expected code %x, got %x", code, actual)
+	}
+	if value := statedb.GetState(addr, common.Hash{}); value != common.BigToHash(big.NewInt(42)) {
+		t.Errorf("expected slot 42, got %v", value)
+	}
+	if err := statedb.Error(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// every call is pinned to the requested block
+	for _, call := range calls {
+		if tag := call.Params[len(call.Params)-1]; tag != "0x1312d00" {
+			t.Errorf("%s: expected block tag 0x1312d00, got %v", call.Method, tag)
+		}
+	}
+	// code and slot are only fetched once
+	if len(calls) != 4 {
+		t.Errorf("expected 4 calls, got %d", len(calls))
+	}
+}
+
+func TestForkDataBaseEmptyAccount(t *testing.T) {
+	var calls []rpcRequest
+	node := newTestNode(t, map[string]string{
+		"eth_getBalance":          "0x0",
+		"eth_getTransactionCount": "0x0",
+		"eth_getCode":             "0x",
+	}, &calls)
+	defer node.Close()
+
+	db := NewForkDataBase(ETH, node.URL, nil)
+	acct, err := db.Account(common.HexToAddress("0xdead"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if acct != nil {
+		t.Errorf("expected no account, got %+v", acct)
+	}
+	if tag := calls[0].Params[1]; tag != "latest" {
+		t.Errorf("expected latest block tag, got %v", tag)
+	}
+
+	// slots of accounts that do not exist are never requested
+	statedb := state.New(db)
+	statedb.GetState(common.HexToAddress("0xdead"), common.Hash{})
+	for _, call := range calls {
+		if call.Method == "eth_getStorageAt" {
+			t.Errorf("unexpected eth_getStorageAt")
+		}
+	}
+}
+
+func TestForkDataBaseRPCError(t *testing.T) {
+	var calls []rpcRequest
+	node := newTestNode(t, map[string]string{}, &calls)
+	defer node.Close()
+
+	statedb := state.New(NewForkDataBase(ETH, node.URL, nil))
+	statedb.GetBalance(common.HexToAddress("0xdead"))
+	if statedb.Error() == nil {
+		t.Errorf("expected rpc error to be recorded")
+	}
+}
+
+func TestForkDataBaseConcurrent(t *testing.T) {
+	var (
+		addr  = common.HexToAddress("0xf75e354c5edc8efed9b59ee9f67a80845ade7d0c")
+		code  = common.FromHex("0x6001600055")
+		calls []rpcRequest
+	)
+	node := newTestNode(t, map[string]string{
+		"eth_getBalance":          "0x64",
+		"eth_getTransactionCount": "0x1",
+		"eth_getCode":             "0x6001600055",
+		"eth_getStorageAt":        "0x000000000000000000000000000000000000000000000000000000000000002a",
+	}, &calls)
+	defer node.Close()
+
+	db := NewForkDataBase(ETH, node.URL, big.NewInt(100))
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			acct, err := db.Account(addr)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			actual, err := db.ContractCode(addr, common.BytesToHash(acct.CodeHash))
+			if err != nil || !bytes.Equal(actual, code) {
+				t.Errorf("expected code %x, got %x (%v)", code, actual, err)
+			}
+			if _, err := db.Storage(addr, common.Hash{}); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// concurrent misses of the same item are coalesced into one request
+	if len(calls) != 4 {
+		t.Errorf("expected 4 requests, got %d", len(calls))
+	}
+}
+
+func TestForkDataBaseChain(t *testing.T) {
+	if db := NewForkDataBase(BSC, "", nil); db.rpc == nil || db.rpc.endpoint != defaultEndpoints[BSC].RPC {
+		t.Errorf("expected the default bsc node")
+	}
+	db := NewOnChainDataBase(IOTEX)
+	if _, err := db.ContractCode(common.HexToAddress("0xc0de"), common.Hash{}); err == nil {
+		t.Errorf("expected error for a chain without explorer")
+	}
+}
@@ -1,27 +1,83 @@
 package onchain
 
 import (
-	"encoding/json"
-	"net/http"
-	"net/url"
-	"time"
+	"fadingrose/rosy-nigh/core/state"
+	"fmt"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/lru"
 	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// memCacheSize bounds the number of fetched items kept in memory
+	memCacheSize = 16384
+	// codeCacheSize bounds the number of contract codes kept in memory
+	codeCacheSize = 1024
 )
 
 // impl Database for support of onchain fuzzing
 // See [Database interface](../core/state/database.go)
+//
+// OnChainDataBase is safe for concurrent use by parallel fuzz workers.
 type OnChainDataBase struct {
-	apikeys   map[Chain]APIKey
-	CodeCache map[common.Hash][]byte
+	chain     Chain
+	endpoints Endpoints
+	apikeys   []APIKey
+	keys      *keyRing
+	CodeCache *lru.Cache[common.Hash, []byte]
+
+	mem      *lru.Cache[cacheKey, []byte] // recently fetched items
+	inflight singleflight.Group           // coalesces concurrent fetches
+
+	// Cache persists fetched data across runs if set
+	Cache *DiskCache
+	// Offline refuses any network access, data missing from Cache fails
+	// with ErrOffline
+	Offline bool
+
+	http *httpClient
+
+	// fork mode, state is read from a JSON-RPC node at a pinned block
+	rpc   *rpcClient
+	block string
 }
 
-func NewOnChainDataBase() *OnChainDataBase {
-	return &OnChainDataBase{
-		apikeys:   ApiKeys(),
-		CodeCache: make(map[common.Hash][]byte),
+var _ state.Database = (*OnChainDataBase)(nil)
+
+// NewOnChainDataBase returns a database fetching code of chain from its
+// explorer, see LoadEndpoints for where the endpoints come from.
+func NewOnChainDataBase(chain Chain) *OnChainDataBase {
+	db := &OnChainDataBase{
+		chain:     chain,
+		endpoints: LoadEndpoints()[chain],
+		block:     "latest",
+		apikeys:   ApiKeys()[chain],
+		CodeCache: lru.NewCache[common.Hash, []byte](codeCacheSize),
+		mem:       lru.NewCache[cacheKey, []byte](memCacheSize),
+	}
+	if err := db.SetTransport(LoadTransportConfig()); err != nil {
+		fmt.Printf("warning: %v, using default http settings\n", err)
+		db.SetTransport(DefaultTransportConfig())
+	}
+	return db
+}
+
+// SetTransport replaces the HTTP transport used to reach explorers and
+// nodes. It must be called before the database is used.
+func (c *OnChainDataBase) SetTransport(cfg TransportConfig) error {
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+	c.http = client
+	c.keys = newKeyRing(c.apikeys, cfg.RateLimit)
+	if c.rpc != nil {
+		c.rpc.client = client
 	}
+	return nil
 }
 
 type ChainImpl interface {
@@ -29,38 +85,53 @@ type ChainImpl interface {
 	GetCodeSize(address string, api APIKey) (int, error)
 }
 
+// ContractCode returns the code of address. A non-zero hash is checked
+// against the fetched code and a mismatch fails with ErrCodeHashMismatch.
 func (c *OnChainDataBase) ContractCode(address common.Address, hash common.Hash) ([]byte, error) {
-	if code, ok := c.CodeCache[hash]; ok {
+	if code, ok := c.CodeCache.Get(hash); ok {
 		return code, nil
 	}
-	// TODO support more chains
-	// only support ETH chain for now
-	eth := Chain(ETH)
-	data, err := eth.GetCode(address.String(), c.apikeys[eth])
+	code, err := c.fetchCode(address)
 	if err != nil {
 		return nil, err
 	}
-
-	hash = hasher(data)
-	c.CodeCache[hash] = data
-
-	return data, nil
+	if hash != (common.Hash{}) {
+		if actual := hasher(code); actual != hash {
+			return nil, fmt.Errorf("%w: %s: expected %s, got %s", ErrCodeHashMismatch, address, hash, actual)
+		}
+	}
+	return code, nil
 }
 
 func (c *OnChainDataBase) ContractCodeSize(address common.Address, hash common.Hash) (int, error) {
-	if code, ok := c.CodeCache[hash]; ok {
-		return len(code), nil
-	}
-	eth := Chain(ETH)
-	data, err := eth.GetCode(address.String(), c.apikeys[eth])
+	code, err := c.ContractCode(address, hash)
 	if err != nil {
 		return 0, err
 	}
+	return len(code), nil
+}
+
+// fetchCode fetches the code of address from the node in fork mode, or the
+// explorer otherwise, and caches it by its hash.
+func (c *OnChainDataBase) fetchCode(address common.Address) ([]byte, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if c.rpc != nil {
+		data, err = c.forkCode(address)
+	} else {
+		data, err = c.cached(c.key(address, cacheCode), func() ([]byte, error) {
+			return c.explorerCode(address)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	hash = hasher(data)
-	c.CodeCache[hash] = data
+	c.CodeCache.Add(hasher(data), data)
 
-	return len(data), nil
+	return data, nil
 }
 
 func hasher(data []byte) common.Hash {
@@ -99,47 +170,16 @@ const (
 )
 
 func (c Chain) String() string {
-	return [...]string{"eth", "goerli", "sepolia", "bsc", "chapel", "polygon", "mumbai", "fantom", "avalanche", "optimism", "arbitrum", "gnosis", "base", "celo", "zkevm", "zkevm_testnet", "blast", "linea", "local", "iotex", "scroll"}[c]
+	return [...]string{"none", "eth", "goerli", "sepolia", "bsc", "chapel", "polygon", "mumbai", "fantom", "avalanche", "optimism", "arbitrum", "gnosis", "base", "celo", "zkevm", "zkevm_testnet", "blast", "linea", "local", "iotex", "scroll"}[c]
 }
 
-func (c Chain) GetCode(address string, api APIKey) ([]byte, error) {
-	args := map[string]string{
-		"ADDRESS": address,
-		"API_KEY": api,
-	}
-	endpoint := c.endpoint(callcode, args)
-	return c.get(endpoint)
-}
-
-func (c Chain) get(endpoint string) ([]byte, error) {
-	proxyURL, err := url.Parse("http://192.168.1.158:7890")
-	if err != nil {
-		panic(err)
-	}
-
-	transport := &http.Transport{
-		Proxy: http.ProxyURL(proxyURL),
-	}
-	client := &http.Client{
-		Transport: transport,
-		Timeout:   time.Second * 10,
-	}
-	resp, err := client.Get(endpoint)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	type sample struct {
-		Jsonrpc string `json:"jsonrpc"`
-		Id      int    `json:"id"`
-		Result  string `json:"result"`
-	}
-	var s sample
-	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+// explorerCode fetches the code of address from the explorer of the chain.
+func (c *OnChainDataBase) explorerCode(address common.Address) ([]byte, error) {
+	var code hexutil.Bytes
+	if err := c.explorerCall(&code, callcode, map[string]string{"ADDRESS": address.String()}); err != nil {
 		return nil, err
 	}
-	return []byte(s.Result), nil
+	return code, nil
 }
 
 func StringToChain(s string) Chain {
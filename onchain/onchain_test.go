@@ -2,73 +2,124 @@ package onchain
 
 import (
 	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 )
 
+// newTestExplorer returns a database whose explorer requests are all
+// answered with body, hits counts the requests served.
+func newTestExplorer(t *testing.T, body string, hits *atomic.Int32) *OnChainDataBase {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	db := NewOnChainDataBase(ETH)
+	if err := db.SetTransport(TransportConfig{Timeout: time.Second, Transport: redirect{server}}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
 func TestOnChainCallCode(t *testing.T) {
-	db := NewOnChainDataBase()
+	code := common.FromHex("0x3660008037602060003660003473273930d21e01ee25e4c219b63259d214872220a261235a5a03f21560015760206000f3")
 
 	tcs := []struct {
-		address  common.Address
+		body     string
 		hash     common.Hash
 		expected []byte
+		err      error
 	}{
 		{
-			address:  common.HexToAddress("0xf75e354c5edc8efed9b59ee9f67a80845ade7d0c"),
-			hash:     common.HexToHash(""),
-			expected: []byte("0x3660008037602060003660003473273930d21e01ee25e4c219b63259d214872220a261235a5a03f21560015760206000f3"),
+			body:     `{"jsonrpc":"2.0","id":1,"result":"0x3660008037602060003660003473273930d21e01ee25e4c219b63259d214872220a261235a5a03f21560015760206000f3"}`,
+			expected: code,
+		},
+		{
+			body:     `{"jsonrpc":"2.0","id":1,"result":"0x3660008037602060003660003473273930d21e01ee25e4c219b63259d214872220a261235a5a03f21560015760206000f3"}`,
+			hash:     crypto.Keccak256Hash(code),
+			expected: code,
+		},
+		{
+			body: `{"jsonrpc":"2.0","id":1,"result":"0x6001"}`,
+			hash: crypto.Keccak256Hash(code),
+			err:  ErrCodeHashMismatch,
+		},
+		{
+			body: `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"invalid argument"}}`,
+			err:  &RPCError{Method: "eth_getCode", Code: -32602, Message: "invalid argument"},
+		},
+		{
+			body: `{"status":"0","message":"NOTOK","result":"Invalid API Key"}`,
+			err:  &ExplorerError{Message: "NOTOK", Result: "Invalid API Key"},
 		},
 	}
 
 	for _, tc := range tcs {
-		actual, err := db.ContractCode(tc.address, tc.hash)
-		if err != nil {
-			t.Errorf("unexpected error: %v", err)
+		var hits atomic.Int32
+		db := newTestExplorer(t, tc.body, &hits)
+		actual, err := db.ContractCode(common.HexToAddress("0xf75e354c5edc8efed9b59ee9f67a80845ade7d0c"), tc.hash)
+		switch want := tc.err.(type) {
+		case nil:
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		case *RPCError:
+			var rpcErr *RPCError
+			if !errors.As(err, &rpcErr) || *rpcErr != *want {
+				t.Errorf("expected %v, got %v", want, err)
+			}
+		case *ExplorerError:
+			var explorerErr *ExplorerError
+			if !errors.As(err, &explorerErr) || *explorerErr != *want {
+				t.Errorf("expected %v, got %v", want, err)
+			}
+		default:
+			if !errors.Is(err, want) {
+				t.Errorf("expected %v, got %v", want, err)
+			}
 		}
 		if !bytes.Equal(actual, tc.expected) {
-			t.Errorf("expected %v, got %v", tc.expected, actual)
+			t.Errorf("expected %x, got %x", tc.expected, actual)
 		}
 	}
 }
 
 func TestOnChainCallCodeBuffer(t *testing.T) {
-	tc := struct {
-		address  common.Address
-		hash     common.Hash
-		expected []byte
-	}{
-		address:  common.HexToAddress("0xf75e354c5edc8efed9b59ee9f67a80845ade7d0c"),
-		hash:     common.HexToHash(""),
-		expected: []byte("0x3660008037602060003660003473273930d21e01ee25e4c219b63259d214872220a261235a5a03f21560015760206000f3"),
-	}
-
-	db := NewOnChainDataBase()
-	data, err := db.ContractCode(tc.address, tc.hash)
-	// here we get bytecode from etherscan, hash it
-	if err != nil {
-		t.Errorf("unexpected error: %v", err)
-	}
-	hasher := crypto.NewKeccakState()
-	hasher.Write(data)
-	buf := common.Hash{}
-	hasher.Read(buf[:])
-
-	tc.hash = buf
+	var (
+		address = common.HexToAddress("0xf75e354c5edc8efed9b59ee9f67a80845ade7d0c")
+		code    = common.FromHex("0x6001600055")
+		hits    atomic.Int32
+	)
+	db := newTestExplorer(t, `{"jsonrpc":"2.0","id":1,"result":"0x6001600055"}`, &hits)
+	hash := crypto.Keccak256Hash(code)
 
 	// concurrency access to the same db, all should get the same result from cached data
 	// if cache is not working, it will cause API limit from etherscan
+	var wg sync.WaitGroup
 	for i := 0; i < 100; i++ {
+		wg.Add(1)
 		go func() {
-			actual, err := db.ContractCode(tc.address, tc.hash)
+			defer wg.Done()
+			actual, err := db.ContractCode(address, hash)
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
-			if !bytes.Equal(actual, tc.expected) {
-				t.Errorf("expected %v, got %v", tc.expected, actual)
+			if !bytes.Equal(actual, code) {
+				t.Errorf("expected %x, got %x", code, actual)
 			}
 		}()
 	}
+	wg.Wait()
+	if n := hits.Load(); n != 1 {
+		t.Errorf("expected 1 request, got %d", n)
+	}
 }
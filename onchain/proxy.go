@@ -0,0 +1,192 @@
+package onchain
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ProxyKind is the pattern a proxy uses to locate its implementation.
+type ProxyKind int
+
+const (
+	NotProxy      ProxyKind = iota
+	EIP1167                 // minimal proxy, implementation is in the code
+	EIP1967                 // implementation slot, incl. OpenZeppelin transparent proxies
+	EIP1967Beacon           // beacon slot, implementation is asked to the beacon
+	EIP1822                 // UUPS PROXIABLE slot
+	ZeppelinOS              // legacy OpenZeppelin implementation slot
+)
+
+func (k ProxyKind) String() string {
+	return [...]string{"none", "eip1167", "eip1967", "eip1967-beacon", "eip1822", "zeppelinos"}[k]
+}
+
+var (
+	// bytes32(uint256(keccak256("eip1967.proxy.implementation")) - 1)
+	eip1967ImplementationSlot = common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")
+	// bytes32(uint256(keccak256("eip1967.proxy.beacon")) - 1)
+	eip1967BeaconSlot = common.HexToHash("0xa3f0ad74e5423aebfd80d3ef4346578335a9a72aeaee59ff6cb3582b35133d50")
+	// bytes32(uint256(keccak256("eip1967.proxy.admin")) - 1)
+	eip1967AdminSlot = common.HexToHash("0xb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d6103")
+	// keccak256("PROXIABLE")
+	eip1822Slot = common.HexToHash("0xc5f16f0fcc639fa48a6947836d9850f504798523bf8c9a3a87d5876cf622bcf7")
+	// keccak256("org.zeppelinos.proxy.implementation")
+	zeppelinOSSlot = common.HexToHash("0x7050c9e0f4ca769c69bd3a8ef740bc37934f8e2c036e5a723fd8ee048ed3f8c3")
+
+	// implementation() of beacons
+	beaconImplementationSelector = common.FromHex("0x5c60da1b")
+)
+
+// minimalProxies are the code of EIP-1167 clones and their PUSH0 variant
+// (EIP-7511) around the 20 bytes implementation address.
+var minimalProxies = []struct{ prefix, suffix []byte }{
+	{
+		prefix: common.FromHex("0x363d3d373d3d3d363d73"),
+		suffix: common.FromHex("0x5af43d82803e903d91602b57fd5bf3"),
+	},
+	{
+		prefix: common.FromHex("0x365f5f375f5f365f73"),
+		suffix: common.FromHex("0x5af43d5f5f3e5f3d91602a57fd5bf3"),
+	},
+}
+
+// ProxyInfo describes a contract and the implementation it delegates to.
+// For contracts which are not proxies Implementation is the contract itself.
+type ProxyInfo struct {
+	Kind  ProxyKind
+	Proxy common.Address
+	// Admin is set for OpenZeppelin transparent proxies
+	Admin common.Address
+	// Beacon is set for beacon proxies
+	Beacon         common.Address
+	Implementation common.Address
+	// Code is the code of the implementation
+	Code []byte
+	// ABI is the verified ABI of the implementation, nil if there is none
+	ABI *abi.ABI
+}
+
+// ResolveProxy detects whether address is a proxy and returns its
+// implementation along with the implementation code and ABI.
+func (c *OnChainDataBase) ResolveProxy(address common.Address) (*ProxyInfo, error) {
+	code, err := c.fetchCode(address)
+	if err != nil {
+		return nil, err
+	}
+	info := &ProxyInfo{Kind: NotProxy, Proxy: address, Implementation: address, Code: code}
+	if impl, ok := minimalProxyTarget(code); ok {
+		info.Kind, info.Implementation = EIP1167, impl
+	} else if err := c.resolveSlots(info); err != nil {
+		return nil, err
+	}
+
+	if info.Kind != NotProxy {
+		if info.Code, err = c.fetchCode(info.Implementation); err != nil {
+			return nil, err
+		}
+	}
+	if c.endpoints.Explorer != "" {
+		info.ABI, err = c.ABI(info.Implementation)
+		if errors.Is(err, ErrNotVerified) {
+			err = nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// resolveSlots looks for an implementation in the standard proxy slots.
+func (c *OnChainDataBase) resolveSlots(info *ProxyInfo) error {
+	for _, s := range []struct {
+		kind ProxyKind
+		slot common.Hash
+	}{
+		{EIP1967, eip1967ImplementationSlot},
+		{EIP1967Beacon, eip1967BeaconSlot},
+		{EIP1822, eip1822Slot},
+		{ZeppelinOS, zeppelinOSSlot},
+	} {
+		value, err := c.readSlot(info.Proxy, s.slot)
+		if err != nil {
+			return err
+		}
+		target := common.BytesToAddress(value.Bytes())
+		if target == (common.Address{}) {
+			continue
+		}
+		info.Kind, info.Implementation = s.kind, target
+		switch s.kind {
+		case EIP1967:
+			admin, err := c.readSlot(info.Proxy, eip1967AdminSlot)
+			if err != nil {
+				return err
+			}
+			info.Admin = common.BytesToAddress(admin.Bytes())
+		case EIP1967Beacon:
+			info.Beacon = target
+			ret, err := c.ethCall(target, beaconImplementationSelector)
+			if err != nil {
+				return err
+			}
+			info.Implementation = common.BytesToAddress(ret)
+		}
+		return nil
+	}
+	return nil
+}
+
+// minimalProxyTarget returns the implementation of an EIP-1167 clone.
+func minimalProxyTarget(code []byte) (common.Address, bool) {
+	for _, p := range minimalProxies {
+		if len(code) == len(p.prefix)+common.AddressLength+len(p.suffix) &&
+			bytes.HasPrefix(code, p.prefix) && bytes.HasSuffix(code, p.suffix) {
+			return common.BytesToAddress(code[len(p.prefix) : len(p.prefix)+common.AddressLength]), true
+		}
+	}
+	return common.Address{}, false
+}
+
+// readSlot reads a storage slot from the node in fork mode, or the proxy
+// module of the explorer otherwise.
+func (c *OnChainDataBase) readSlot(address common.Address, key common.Hash) (common.Hash, error) {
+	if c.rpc != nil {
+		return c.Storage(address, key)
+	}
+	k := c.key(address, cacheStorage)
+	k.slot = key
+	value, err := c.cached(k, func() ([]byte, error) {
+		var value hexutil.Bytes
+		err := c.explorerCall(&value, getstorageat, map[string]string{"ADDRESS": address.String(), "POSITION": key.Hex()})
+		if err != nil {
+			return nil, err
+		}
+		return value, nil
+	})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(value), nil
+}
+
+// ethCall executes a read-only call of to with data at the pinned block.
+func (c *OnChainDataBase) ethCall(to common.Address, data []byte) ([]byte, error) {
+	var ret hexutil.Bytes
+	if c.rpc != nil {
+		msg := map[string]interface{}{"to": to, "data": hexutil.Bytes(data)}
+		if err := c.rpc.call(&ret, "eth_call", msg, c.block); err != nil {
+			return nil, err
+		}
+		return ret, nil
+	}
+	err := c.explorerCall(&ret, ethcall, map[string]string{"ADDRESS": to.String(), "DATA": hexutil.Encode(data)})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -0,0 +1,173 @@
+package onchain
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// chainState is the state served by newTestChain
+type chainState struct {
+	code     map[common.Address]string
+	storage  map[common.Address]map[common.Hash]common.Hash
+	calls    map[common.Address]string
+	verified map[common.Address]bool
+}
+
+// newTestChain returns a fork database backed by a stand-in node serving
+// state, verified contracts get testABI from the stand-in explorer.
+func newTestChain(t *testing.T, state chainState) *OnChainDataBase {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			address := common.HexToAddress(r.URL.Query().Get("address"))
+			if !state.verified[address] {
+				w.Write([]byte(`{"status":"0","message":"NOTOK","result":"Contract source code not verified"}`))
+				return
+			}
+			result, _ := json.Marshal(testABI)
+			w.Write([]byte(`{"status":"1","message":"OK","result":` + string(result) + `}`))
+			return
+		}
+
+		var req struct {
+			Id     uint64            `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request: %v", err)
+			return
+		}
+		var (
+			address common.Address
+			result  string
+		)
+		switch req.Method {
+		case "eth_getCode":
+			json.Unmarshal(req.Params[0], &address)
+			result = state.code[address]
+		case "eth_getStorageAt":
+			var slot common.Hash
+			json.Unmarshal(req.Params[0], &address)
+			json.Unmarshal(req.Params[1], &slot)
+			result = state.storage[address][slot].Hex()
+		case "eth_call":
+			var msg struct {
+				To   common.Address `json:"to"`
+				Data hexutil.Bytes  `json:"data"`
+			}
+			json.Unmarshal(req.Params[0], &msg)
+			result = state.calls[msg.To]
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+		if result == "" {
+			result = "0x"
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.Id, "result": result})
+	}))
+	t.Cleanup(server.Close)
+
+	db := NewForkDataBase(ETH, server.URL, nil)
+	if err := db.SetTransport(TransportConfig{Timeout: time.Second, Transport: redirect{server}}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestResolveProxy(t *testing.T) {
+	var (
+		proxy  = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		impl   = common.HexToAddress("0x2000000000000000000000000000000000000002")
+		beacon = common.HexToAddress("0x3000000000000000000000000000000000000003")
+		admin  = common.HexToAddress("0x4000000000000000000000000000000000000004")
+		code   = "0x6001600055"
+		// a delegating stub, only the slots matter
+		stub = "0x366000803760008036600073"
+	)
+	word := func(addr common.Address) common.Hash { return common.BytesToHash(addr.Bytes()) }
+	implHex := strings.TrimPrefix(impl.Hex(), "0x")
+
+	tcs := []struct {
+		name    string
+		state   chainState
+		kind    ProxyKind
+		admin   common.Address
+		beacon  common.Address
+		impl    common.Address
+		withABI bool
+	}{
+		{
+			name: "eip1167",
+			state: chainState{code: map[common.Address]string{
+				proxy: "0x363d3d373d3d3d363d73" + implHex + "5af43d82803e903d91602b57fd5bf3",
+				impl:  code,
+			}, verified: map[common.Address]bool{impl: true}},
+			kind: EIP1167, impl: impl, withABI: true,
+		},
+		{
+			name: "eip7511",
+			state: chainState{code: map[common.Address]string{
+				proxy: "0x365f5f375f5f365f73" + implHex + "5af43d5f5f3e5f3d91602a57fd5bf3",
+				impl:  code,
+			}},
+			kind: EIP1167, impl: impl,
+		},
+		{
+			name: "transparent",
+			state: chainState{
+				code: map[common.Address]string{proxy: stub, impl: code},
+				storage: map[common.Address]map[common.Hash]common.Hash{proxy: {
+					eip1967ImplementationSlot: word(impl),
+					eip1967AdminSlot:          word(admin),
+				}},
+			},
+			kind: EIP1967, admin: admin, impl: impl,
+		},
+		{
+			name: "beacon",
+			state: chainState{
+				code:    map[common.Address]string{proxy: stub, impl: code},
+				storage: map[common.Address]map[common.Hash]common.Hash{proxy: {eip1967BeaconSlot: word(beacon)}},
+				calls:   map[common.Address]string{beacon: word(impl).Hex()},
+			},
+			kind: EIP1967Beacon, beacon: beacon, impl: impl,
+		},
+		{
+			name: "uups",
+			state: chainState{
+				code:    map[common.Address]string{proxy: stub, impl: code},
+				storage: map[common.Address]map[common.Hash]common.Hash{proxy: {eip1822Slot: word(impl)}},
+			},
+			kind: EIP1822, impl: impl,
+		},
+		{
+			name:  "none",
+			state: chainState{code: map[common.Address]string{proxy: code}},
+			kind:  NotProxy, impl: proxy,
+		},
+	}
+
+	for _, tc := range tcs {
+		info, err := newTestChain(t, tc.state).ResolveProxy(proxy)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if info.Kind != tc.kind || info.Implementation != tc.impl || info.Admin != tc.admin || info.Beacon != tc.beacon {
+			t.Errorf("%s: unexpected proxy info %+v", tc.name, info)
+		}
+		if hexutil.Encode(info.Code) != code {
+			t.Errorf("%s: expected implementation code %s, got %x", tc.name, code, info.Code)
+		}
+		if (info.ABI != nil) != tc.withABI {
+			t.Errorf("%s: expected abi %v, got %v", tc.name, tc.withABI, info.ABI != nil)
+		}
+	}
+}
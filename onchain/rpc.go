@@ -0,0 +1,68 @@
+package onchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// rpcClient is a minimal JSON-RPC 2.0 client over HTTP, enough for the
+// handful of eth_* calls needed to fork state from a node.
+type rpcClient struct {
+	endpoint string
+	client   *httpClient
+	id       atomic.Uint64
+}
+
+func newRPCClient(endpoint string, client *httpClient) *rpcClient {
+	return &rpcClient{endpoint: endpoint, client: client}
+}
+
+type rpcRequest struct {
+	Jsonrpc string        `json:"jsonrpc"`
+	Id      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Id      uint64          `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *RPCError       `json:"error"`
+}
+
+// call invokes method with params and decodes the result into result.
+func (c *rpcClient) call(result interface{}, method string, params ...interface{}) error {
+	body, err := json.Marshal(rpcRequest{
+		Jsonrpc: "2.0",
+		Id:      c.id.Add(1),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.post(c.endpoint, "application/json", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %s", method, resp.Status)
+	}
+
+	var r rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	if r.Error != nil {
+		r.Error.Method = method
+		return r.Error
+	}
+	if err := json.Unmarshal(r.Result, result); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	return nil
+}
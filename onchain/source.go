@@ -0,0 +1,161 @@
+package onchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrNotVerified is returned when the explorer has no verified source for a
+// contract.
+var ErrNotVerified = errors.New("contract source code not verified")
+
+// notVerified is how explorers report unverified contracts
+const notVerified = "Contract source code not verified"
+
+// SourceInfo is the verified source of a contract as published on the
+// explorer of its chain.
+type SourceInfo struct {
+	Name             string
+	CompilerVersion  string
+	OptimizationUsed bool
+	Runs             int
+	EVMVersion       string
+	// Sources maps file names to their content, single file contracts are
+	// stored under <Name>.sol
+	Sources map[string]string
+	// ABI is the JSON ABI of the contract
+	ABI             string
+	ConstructorArgs []byte
+	// Implementation is the implementation reported by the explorer if the
+	// contract is a proxy
+	Implementation common.Address
+}
+
+// sourceResult is one entry of the getsourcecode result
+type sourceResult struct {
+	SourceCode           string
+	ABI                  string
+	ContractName         string
+	CompilerVersion      string
+	OptimizationUsed     string
+	Runs                 string
+	ConstructorArguments string
+	EVMVersion           string
+	Implementation       string
+}
+
+// Source fetches the verified source of address, it fails with
+// ErrNotVerified if there is none. Only verified sources are cached, a
+// contract may get verified later.
+func (c *OnChainDataBase) Source(address common.Address) (*SourceInfo, error) {
+	data, err := c.cached(c.contractKey(address, cacheSource), func() ([]byte, error) {
+		var results []sourceResult
+		if err := c.explorerCall(&results, getsourcecode, map[string]string{"ADDRESS": address.String()}); err != nil {
+			return nil, err
+		}
+		if len(results) == 0 || results[0].ABI == notVerified || results[0].SourceCode == "" {
+			return nil, fmt.Errorf("%w: %s", ErrNotVerified, address)
+		}
+		return json.Marshal(results[0])
+	})
+	if err != nil {
+		return nil, err
+	}
+	var r sourceResult
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("%s: %w", getsourcecode, err)
+	}
+
+	sources, err := parseSources(r.ContractName, r.SourceCode)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", getsourcecode, err)
+	}
+	info := &SourceInfo{
+		Name:             r.ContractName,
+		CompilerVersion:  r.CompilerVersion,
+		OptimizationUsed: r.OptimizationUsed == "1",
+		EVMVersion:       r.EVMVersion,
+		Sources:          sources,
+		ABI:              r.ABI,
+		ConstructorArgs:  common.FromHex(r.ConstructorArguments),
+	}
+	if runs, err := strconv.Atoi(r.Runs); err == nil {
+		info.Runs = runs
+	}
+	if common.IsHexAddress(r.Implementation) {
+		info.Implementation = common.HexToAddress(r.Implementation)
+	}
+	return info, nil
+}
+
+// parseSources splits the SourceCode field into files. Explorers return
+// either a single flattened file, a JSON object of sources, or a standard
+// JSON input wrapped in an extra pair of braces.
+func parseSources(name, code string) (map[string]string, error) {
+	var files map[string]struct {
+		Content string `json:"content"`
+	}
+	trimmed := strings.TrimSpace(code)
+	switch {
+	case strings.HasPrefix(trimmed, "{{") && strings.HasSuffix(trimmed, "}}"):
+		var input struct {
+			Sources json.RawMessage `json:"sources"`
+		}
+		if err := json.Unmarshal([]byte(trimmed[1:len(trimmed)-1]), &input); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(input.Sources, &files); err != nil {
+			return nil, err
+		}
+	case strings.HasPrefix(trimmed, "{"):
+		if err := json.Unmarshal([]byte(trimmed), &files); err != nil {
+			return nil, err
+		}
+	default:
+		return map[string]string{name + ".sol": code}, nil
+	}
+
+	ret := make(map[string]string, len(files))
+	for file, f := range files {
+		ret[file] = f.Content
+	}
+	return ret, nil
+}
+
+// ABI fetches the verified ABI of address, e.g. to pack well-typed calldata
+// for deployed targets. It fails with ErrNotVerified if there is none.
+func (c *OnChainDataBase) ABI(address common.Address) (*abi.ABI, error) {
+	data, err := c.cached(c.contractKey(address, cacheABI), func() ([]byte, error) {
+		var result string
+		err := c.explorerCall(&result, getabi, map[string]string{"ADDRESS": address.String()})
+		var explorerErr *ExplorerError
+		if errors.As(err, &explorerErr) && explorerErr.Result == notVerified {
+			return nil, fmt.Errorf("%w: %s", ErrNotVerified, address)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []byte(result), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := abi.JSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", getabi, err)
+	}
+	return &parsed, nil
+}
+
+// contractKey keys data that does not depend on the block, like verified
+// sources.
+func (c *OnChainDataBase) contractKey(address common.Address, kind cacheKind) cacheKey {
+	return cacheKey{chain: c.chain, address: address, kind: kind}
+}
@@ -0,0 +1,142 @@
+package onchain
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const testABI = `[{"type":"function","name":"transfer","stateMutability":"nonpayable","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]}]`
+
+// newTestSourceExplorer answers getsourcecode and getabi with the given
+// results, keyed by action.
+func newTestSourceExplorer(t *testing.T, bodies map[string]string, hits *atomic.Int32) *OnChainDataBase {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(bodies[r.URL.Query().Get("action")]))
+	}))
+	t.Cleanup(server.Close)
+
+	db := NewOnChainDataBase(ETH)
+	if err := db.SetTransport(TransportConfig{Timeout: time.Second, Transport: redirect{server}}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSource(t *testing.T) {
+	var (
+		addr = common.HexToAddress("0xc0de")
+		hits atomic.Int32
+	)
+	abiJSON, _ := json.Marshal(testABI)
+	db := newTestSourceExplorer(t, map[string]string{
+		"getsourcecode": `{"status":"1","message":"OK","result":[{
+			"SourceCode":"{{\"language\":\"Solidity\",\"sources\":{\"src/Token.sol\":{\"content\":\"contract Token {}\"},\"src/Lib.sol\":{\"content\":\"library Lib {}\"}}}}",
+			"ABI":` + string(abiJSON) + `,
+			"ContractName":"Token","CompilerVersion":"v0.8.24+commit.e11b9ed9",
+			"OptimizationUsed":"1","Runs":"200","ConstructorArguments":"000000000000000000000000000000000000000000000000000000000000002a",
+			"EVMVersion":"paris","Proxy":"1","Implementation":"0x000000000000000000000000000000000000beef"}]}`,
+		"getabi": `{"status":"1","message":"OK","result":` + string(abiJSON) + `}`,
+	}, &hits)
+
+	info, err := db.Source(addr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Name != "Token" || info.CompilerVersion != "v0.8.24+commit.e11b9ed9" || !info.OptimizationUsed || info.Runs != 200 || info.EVMVersion != "paris" {
+		t.Errorf("unexpected compiler settings %+v", info)
+	}
+	if len(info.Sources) != 2 || info.Sources["src/Token.sol"] != "contract Token {}" {
+		t.Errorf("unexpected sources %v", info.Sources)
+	}
+	if len(info.ConstructorArgs) != 32 || info.ConstructorArgs[31] != 42 {
+		t.Errorf("unexpected constructor args %x", info.ConstructorArgs)
+	}
+	if info.Implementation != common.HexToAddress("0xbeef") {
+		t.Errorf("expected implementation 0xbeef, got %v", info.Implementation)
+	}
+
+	parsed, err := db.ABI(addr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	calldata, err := parsed.Pack("transfer", common.HexToAddress("0xbeef"), big.NewInt(1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(calldata) != 4+32*2 {
+		t.Errorf("expected 68 bytes of calldata, got %d", len(calldata))
+	}
+
+	// both are served from memory afterwards
+	db.Source(addr)
+	db.ABI(addr)
+	if n := hits.Load(); n != 2 {
+		t.Errorf("expected 2 requests, got %d", n)
+	}
+}
+
+func TestSourceNotVerified(t *testing.T) {
+	var hits atomic.Int32
+	db := newTestSourceExplorer(t, map[string]string{
+		"getsourcecode": `{"status":"1","message":"OK","result":[{"SourceCode":"","ABI":"Contract source code not verified","ContractName":""}]}`,
+		"getabi":        `{"status":"0","message":"NOTOK","result":"Contract source code not verified"}`,
+	}, &hits)
+
+	addr := common.HexToAddress("0xc0de")
+	for i := 0; i < 2; i++ {
+		if _, err := db.Source(addr); !errors.Is(err, ErrNotVerified) {
+			t.Errorf("expected %v, got %v", ErrNotVerified, err)
+		}
+		if _, err := db.ABI(addr); !errors.Is(err, ErrNotVerified) {
+			t.Errorf("expected %v, got %v", ErrNotVerified, err)
+		}
+	}
+	// unverified contracts are not cached
+	if n := hits.Load(); n != 4 {
+		t.Errorf("expected 4 requests, got %d", n)
+	}
+}
+
+func TestParseSources(t *testing.T) {
+	tcs := []struct {
+		code     string
+		expected map[string]string
+	}{
+		{
+			code:     "pragma solidity ^0.8.0;\ncontract A {}",
+			expected: map[string]string{"A.sol": "pragma solidity ^0.8.0;\ncontract A {}"},
+		},
+		{
+			code:     `{"A.sol":{"content":"contract A {}"},"B.sol":{"content":"contract B {}"}}`,
+			expected: map[string]string{"A.sol": "contract A {}", "B.sol": "contract B {}"},
+		},
+		{
+			code:     `{{"language":"Solidity","sources":{"A.sol":{"content":"contract A {}"}},"settings":{}}}`,
+			expected: map[string]string{"A.sol": "contract A {}"},
+		},
+	}
+	for _, tc := range tcs {
+		actual, err := parseSources("A", tc.code)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			continue
+		}
+		if len(actual) != len(tc.expected) {
+			t.Errorf("expected %v, got %v", tc.expected, actual)
+		}
+		for name, content := range tc.expected {
+			if actual[name] != content {
+				t.Errorf("%s: expected %q, got %q", name, content, actual[name])
+			}
+		}
+	}
+}
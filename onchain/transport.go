@@ -0,0 +1,188 @@
+package onchain
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pelletier/go-toml"
+)
+
+// TransportConfig configures how explorers and nodes are reached over HTTP.
+// It is read from the [http] table of keys.toml:
+//
+//	[http]
+//	proxy = "http://127.0.0.1:7890"
+//	timeout = "10s"
+//	retries = 3
+//	backoff = "500ms"
+//	user_agent = "rosy-nigh"
+//	rate_limit = 5
+type TransportConfig struct {
+	// Proxy is the proxy URL, if empty HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	// from the environment are used
+	Proxy string
+	// Timeout bounds a single attempt, including reading the body
+	Timeout time.Duration
+	// Retries is the number of extra attempts on network errors, 429 and 5xx
+	Retries int
+	// Backoff is the delay before the first retry, doubled on each retry
+	Backoff time.Duration
+	// UserAgent is sent with every request
+	UserAgent string
+	// RateLimit is the number of explorer requests per second allowed for
+	// each API key, 0 disables limiting
+	RateLimit float64
+	// Transport overrides the underlying round tripper, Proxy is ignored
+	// if set. Tests use it to redirect requests to a local server.
+	Transport http.RoundTripper
+}
+
+// DefaultTransportConfig returns the transport used when keys.toml does not
+// say otherwise.
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		Timeout:   time.Second * 10,
+		Retries:   3,
+		Backoff:   time.Millisecond * 500,
+		UserAgent: "rosy-nigh",
+		RateLimit: 5,
+	}
+}
+
+// maxBackoff caps the delay between two attempts
+const maxBackoff = time.Second * 30
+
+// LoadTransportConfig reads the [http] table of keys.toml on top of the
+// defaults, missing or malformed entries keep their default.
+func LoadTransportConfig() TransportConfig {
+	keys, err := apikeysFromFile()
+	if err != nil {
+		return DefaultTransportConfig()
+	}
+	return parseTransportConfig(keys)
+}
+
+func parseTransportConfig(keys []byte) TransportConfig {
+	cfg := DefaultTransportConfig()
+	tree, err := toml.LoadBytes(keys)
+	if err != nil {
+		fmt.Println("warning: failed to unmarshal keys.toml, using default http settings")
+		return cfg
+	}
+	if proxy, ok := tree.Get("http.proxy").(string); ok {
+		cfg.Proxy = proxy
+	}
+	if retries, ok := tree.Get("http.retries").(int64); ok && retries >= 0 {
+		cfg.Retries = int(retries)
+	}
+	if agent, ok := tree.Get("http.user_agent").(string); ok {
+		cfg.UserAgent = agent
+	}
+	switch rate := tree.Get("http.rate_limit").(type) {
+	case int64:
+		cfg.RateLimit = float64(rate)
+	case float64:
+		cfg.RateLimit = rate
+	}
+	for key, d := range map[string]*time.Duration{"http.timeout": &cfg.Timeout, "http.backoff": &cfg.Backoff} {
+		s, ok := tree.Get(key).(string)
+		if !ok {
+			continue
+		}
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			fmt.Printf("warning: invalid %s %q in keys.toml: %v\n", key, s, err)
+			continue
+		}
+		*d = v
+	}
+	return cfg
+}
+
+// httpClient sends requests with the configured user agent and retries
+// transient failures with exponential backoff.
+type httpClient struct {
+	client    *http.Client
+	retries   int
+	backoff   time.Duration
+	userAgent string
+}
+
+func newHTTPClient(cfg TransportConfig) (*httpClient, error) {
+	transport := cfg.Transport
+	if transport == nil {
+		proxy := http.ProxyFromEnvironment
+		if cfg.Proxy != "" {
+			proxyURL, err := url.Parse(cfg.Proxy)
+			if err != nil {
+				return nil, fmt.Errorf("invalid proxy %q: %w", cfg.Proxy, err)
+			}
+			proxy = http.ProxyURL(proxyURL)
+		}
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.Proxy = proxy
+		transport = t
+	}
+	return &httpClient{
+		client:    &http.Client{Transport: transport, Timeout: cfg.Timeout},
+		retries:   cfg.Retries,
+		backoff:   cfg.Backoff,
+		userAgent: cfg.UserAgent,
+	}, nil
+}
+
+// retryable reports whether a response with the given status may succeed
+// if sent again.
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// do sends a request built from method, endpoint and body and returns the
+// first response that is not retryable, or the last one once retries are
+// exhausted. The caller must close the response body.
+func (c *httpClient) do(method, endpoint, contentType string, body []byte) (*http.Response, error) {
+	delay := c.backoff
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(method, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if c.userAgent != "" {
+			req.Header.Set("User-Agent", c.userAgent)
+		}
+		resp, err := c.client.Do(req)
+		if attempt >= c.retries || (err == nil && !retryable(resp.StatusCode)) {
+			return resp, err
+		}
+
+		wait := delay
+		if err == nil {
+			// honour the server's hint on rate limiting
+			if secs, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil && secs >= 0 {
+				wait = time.Duration(secs) * time.Second
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		time.Sleep(min(wait, maxBackoff))
+		delay = min(delay*2, maxBackoff)
+	}
+}
+
+// get issues a GET request to endpoint.
+func (c *httpClient) get(endpoint string) (*http.Response, error) {
+	return c.do(http.MethodGet, endpoint, "", nil)
+}
+
+// post issues a POST request of body to endpoint.
+func (c *httpClient) post(endpoint, contentType string, body []byte) (*http.Response, error) {
+	return c.do(http.MethodPost, endpoint, contentType, body)
+}
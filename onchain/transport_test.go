@@ -0,0 +1,133 @@
+package onchain
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// redirect sends every request to server, whatever its original host.
+type redirect struct {
+	server *httptest.Server
+}
+
+func (r redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, _ := url.Parse(r.server.URL)
+	req = req.Clone(req.Context())
+	req.URL.Scheme = target.Scheme
+	req.URL.Host = target.Host
+	return r.server.Client().Transport.RoundTrip(req)
+}
+
+func TestTransportRetries(t *testing.T) {
+	tcs := []struct {
+		failures int32 // responses failing before the first success
+		status   int   // status of the failing responses
+		expected int   // final status
+		attempts int32
+	}{
+		{failures: 0, status: http.StatusServiceUnavailable, expected: http.StatusOK, attempts: 1},
+		{failures: 2, status: http.StatusServiceUnavailable, expected: http.StatusOK, attempts: 3},
+		{failures: 2, status: http.StatusTooManyRequests, expected: http.StatusOK, attempts: 3},
+		{failures: 5, status: http.StatusBadGateway, expected: http.StatusBadGateway, attempts: 4},
+		{failures: 5, status: http.StatusNotFound, expected: http.StatusNotFound, attempts: 1},
+	}
+
+	for _, tc := range tcs {
+		var attempts atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if agent := r.Header.Get("User-Agent"); agent != "fuzzer/1.0" {
+				t.Errorf("expected user agent fuzzer/1.0, got %q", agent)
+			}
+			if attempts.Add(1) <= tc.failures {
+				w.WriteHeader(tc.status)
+			}
+		}))
+
+		client, err := newHTTPClient(TransportConfig{
+			Timeout:   time.Second,
+			Retries:   3,
+			Backoff:   time.Millisecond,
+			UserAgent: "fuzzer/1.0",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.get(server.URL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.expected {
+			t.Errorf("expected status %d, got %d", tc.expected, resp.StatusCode)
+		}
+		if n := attempts.Load(); n != tc.attempts {
+			t.Errorf("expected %d attempts, got %d", tc.attempts, n)
+		}
+		server.Close()
+	}
+}
+
+func TestTransportConfig(t *testing.T) {
+	cfg := parseTransportConfig([]byte(`
+eth = "KEY"
+
+[http]
+proxy = "http://127.0.0.1:7890"
+timeout = "3s"
+retries = 5
+user_agent = "fuzzer/1.0"
+backoff = "oops"
+`))
+	expected := DefaultTransportConfig()
+	expected.Proxy = "http://127.0.0.1:7890"
+	expected.Timeout = time.Second * 3
+	expected.Retries = 5
+	expected.UserAgent = "fuzzer/1.0"
+	if cfg != expected {
+		t.Errorf("expected %+v, got %+v", expected, cfg)
+	}
+
+	if _, err := newHTTPClient(TransportConfig{Proxy: "://bad"}); err == nil {
+		t.Errorf("expected error for invalid proxy")
+	}
+}
+
+func TestOnChainDataBaseTransport(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x6001600055"}`))
+	}))
+	defer server.Close()
+
+	db := NewOnChainDataBase(ETH)
+	err := db.SetTransport(TransportConfig{
+		Timeout:   time.Second,
+		Retries:   1,
+		Backoff:   time.Millisecond,
+		Transport: redirect{server},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := db.ContractCode(common.HexToAddress("0xc0de"), common.Hash{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := common.FromHex("0x6001600055"); !bytes.Equal(code, expected) {
+		t.Errorf("expected %x, got %x", expected, code)
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Errorf("expected 2 attempts, got %d", n)
+	}
+}